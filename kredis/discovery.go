@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ereOn/kredis/pkg/kredis"
+)
+
+var discovery string
+var discoveryFile string
+var discoveryDNSService string
+var discoveryDNSProto string
+var discoveryDNSName string
+var discoveryKubernetesNamespace string
+var discoveryKubernetesSelector string
+var discoveryKubernetesGroupLabel string
+var discoveryInstancesPerGroup int
+var discoveryPort string
+
+// newDiscoverer creates the discoverer selected by the discovery flags.
+//
+// Master groups specified as arguments are only allowed for the static
+// discovery.
+func newDiscoverer(args []string) (kredis.Discoverer, error) {
+	if discovery != "static" && len(args) > 0 {
+		return nil, fmt.Errorf("master groups can't be specified as arguments with the %s discovery", discovery)
+	}
+
+	switch discovery {
+	case "static":
+		masterGroups, err := parseMasterGroups(args)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return kredis.StaticDiscoverer(masterGroups), nil
+	case "file":
+		if discoveryFile == "" {
+			return nil, errors.New("--discovery-file is required with the file discovery")
+		}
+
+		return &kredis.FileDiscoverer{Path: discoveryFile}, nil
+	case "dns":
+		if discoveryDNSName == "" {
+			return nil, errors.New("--discovery-dns-name is required with the dns discovery")
+		}
+
+		return &kredis.DNSDiscoverer{
+			Service:           discoveryDNSService,
+			Proto:             discoveryDNSProto,
+			Name:              discoveryDNSName,
+			InstancesPerGroup: discoveryInstancesPerGroup,
+		}, nil
+	case "kubernetes":
+		client, err := kredis.NewInClusterKubernetesClient()
+
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes discovery: %s", err)
+		}
+
+		namespace := discoveryKubernetesNamespace
+
+		if namespace == "" {
+			if namespace, err = kredis.InClusterNamespace(); err != nil {
+				return nil, fmt.Errorf("kubernetes discovery: %s", err)
+			}
+		}
+
+		return &kredis.KubernetesPodsDiscoverer{
+			Client:            client,
+			Namespace:         namespace,
+			LabelSelector:     discoveryKubernetesSelector,
+			GroupLabel:        discoveryKubernetesGroupLabel,
+			InstancesPerGroup: discoveryInstancesPerGroup,
+			Port:              discoveryPort,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown discovery \"%s\"", discovery)
+	}
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&discovery, "discovery", "static", "The source of the master groups. One of: static, file, dns, kubernetes.")
+	rootCmd.PersistentFlags().StringVar(&discoveryFile, "discovery-file", "", "The file to read master groups from, as a JSON array or one master group per line. Changes are picked up on the next sync cycle.")
+	rootCmd.PersistentFlags().StringVar(&discoveryDNSService, "discovery-dns-service", "redis", "The service of the SRV records to look up.")
+	rootCmd.PersistentFlags().StringVar(&discoveryDNSProto, "discovery-dns-proto", "tcp", "The protocol of the SRV records to look up.")
+	rootCmd.PersistentFlags().StringVar(&discoveryDNSName, "discovery-dns-name", "", "The domain name of the headless service whose SRV records list the Redis instances. The service must publish not ready addresses.")
+	rootCmd.PersistentFlags().StringVar(&discoveryKubernetesNamespace, "discovery-kubernetes-namespace", "", "The namespace of the Redis pods. Defaults to the namespace of the pod.")
+	rootCmd.PersistentFlags().StringVar(&discoveryKubernetesSelector, "discovery-kubernetes-selector", "", "The label selector of the Redis pods.")
+	rootCmd.PersistentFlags().StringVar(&discoveryKubernetesGroupLabel, "discovery-kubernetes-group-label", "", "The label whose value groups Redis pods in master groups. If empty, pods are grouped by ordinal.")
+	rootCmd.PersistentFlags().IntVar(&discoveryInstancesPerGroup, "discovery-instances-per-group", 3, "The number of instances per master group, when grouping by ordinal.")
+	rootCmd.PersistentFlags().StringVar(&discoveryPort, "discovery-port", "6379", "The Redis port of the discovered pods.")
+}
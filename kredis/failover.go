@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/ereOn/kredis/pkg/kredis"
+	"github.com/go-kit/kit/log"
+	"github.com/spf13/cobra"
+)
+
+var failoverReplica string
+var failoverTimeout time.Duration
+
+var failoverCmd = &cobra.Command{
+	Use:   "failover <master-group>",
+	Short: "Promote a replica of a master group once it caught up with its master, for planned maintenance.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		masterGroup, err := kredis.ParseMasterGroup(args[0])
+
+		if err != nil {
+			return err
+		}
+
+		if len(masterGroup) == 0 {
+			return errors.New("the master group is empty")
+		}
+
+		var replica kredis.RedisInstance
+
+		if failoverReplica != "" {
+			if replica, err = kredis.ParseRedisInstance(failoverReplica); err != nil {
+				return err
+			}
+		}
+
+		cmd.SilenceUsage = true
+
+		logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+		pool, err := newPool()
+
+		if err != nil {
+			return err
+		}
+
+		defer pool.Close()
+
+		manager, err := newManager(logger, pool)
+
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := WithCancelOnInterupt(context.Background())
+		defer cancel()
+
+		if err = manager.Switchover(ctx, masterGroup, replica, failoverTimeout); err != nil {
+			return err
+		}
+
+		logger.Log("event", "switchover completed", "master-group", masterGroup)
+
+		return nil
+	},
+}
+
+func init() {
+	failoverCmd.Flags().StringVar(&failoverReplica, "replica", "", "The replica to promote. Defaults to the replica with the highest replication offset.")
+	failoverCmd.Flags().DurationVar(&failoverTimeout, "timeout", time.Second*30, "The maximum time for the replication offsets to converge, and then again for the roles to converge.")
+	rootCmd.AddCommand(failoverCmd)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+var httpShutdownTimeout = time.Second * 5
+
+// serveHTTP serves the specified handler on the specified address until the
+// context expires.
+func serveHTTP(ctx context.Context, logger log.Logger, address string, handler http.Handler) {
+	server := &http.Server{
+		Addr:    address,
+		Handler: handler,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+
+		server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Log("event", "http server starting", "address", address)
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Log("event", "http server failure", "error", err)
+	}
+}
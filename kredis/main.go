@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -33,60 +37,359 @@ func WithCancelOnInterupt(ctx context.Context) (context.Context, func()) {
 	return ctx, cancel
 }
 
+var listenAddress string
+var livenessThreshold time.Duration
+var connectTimeout time.Duration
+var readTimeout time.Duration
+var writeTimeout time.Duration
+var commandTimeout time.Duration
+var healthCheckPeriod time.Duration
+var circuitBreakerThreshold int
+var circuitBreakerBackoff time.Duration
+var circuitBreakerMaxBackoff time.Duration
+var concurrency int
+var nodeTimeout time.Duration
+var allowUnreachable bool
+var managedSlots string
+var slotsTolerance int
+var weights []string
+var maxMemoryWeights bool
+var pins []string
+var topologyCommand string
+var failoverPolicy string
+var failoverDelay time.Duration
+var drainGracePeriod time.Duration
+var forgetGracePeriod time.Duration
+var username string
+var password string
+var passwordFile string
+var useTLS bool
+var tlsCAFile string
+var tlsCertFile string
+var tlsKeyFile string
+var tlsServerName string
+var tlsSkipVerify bool
+var leaderElection bool
+var leaderElectionNamespace string
+var leaderElectionName string
+var leaderElectionIdentity string
+var leaderElectionLeaseDuration time.Duration
+var leaderElectionRetryPeriod time.Duration
+
 var rootCmd = &cobra.Command{
-	Use:   "kredis <master-group>...",
+	Use:   "kredis [master-group...]",
 	Short: "A tool to manage Redis clusters in Kubernetes.",
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
-		masterGroups := make([]kredis.MasterGroup, len(args))
-
-		for i, arg := range args {
-			masterGroups[i], err = kredis.ParseMasterGroup(arg)
+		discoverer, err := newDiscoverer(args)
 
-			if err != nil {
-				return fmt.Errorf("parsing argument %d: %s", i, err)
-			}
-		}
-
-		if len(masterGroups) == 0 {
-			return errors.New("no master groups specified - refusing to run")
+		if err != nil {
+			return err
 		}
 
 		cmd.SilenceUsage = true
 
 		logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
 
-		logger.Log("event", "master groups", "count", len(masterGroups))
+		pool, err := newPool()
 
-		for i, masterGroup := range masterGroups {
-			logger.Log("event", "master group", "index", i, "master-group", masterGroup)
+		if err != nil {
+			return err
 		}
 
-		pool := &kredis.Pool{
-			IdleTimeout: time.Second * 90,
-			MaxActive:   10,
-			MaxIdle:     2,
-		}
 		defer pool.Close()
 
-		manager := &kredis.Manager{
-			Logger:                 logger,
-			Pool:                   pool,
-			SyncPeriod:             time.Second,
-			WarningPeriodThreshold: time.Second * 10,
-			MaxSlots:               100,
+		manager, err := newManager(logger, pool)
+
+		if err != nil {
+			return err
 		}
 
+		manager.Metrics = &kredis.Metrics{}
+
 		logger.Log("event", "started")
 		defer logger.Log("event", "stopped")
 
 		ctx, cancel := WithCancelOnInterupt(context.Background())
 		defer cancel()
 
-		manager.Run(ctx, masterGroups)
+		var elector *kredis.LeaderElector
+
+		if leaderElection {
+			if elector, err = newLeaderElector(logger); err != nil {
+				return err
+			}
+		}
+
+		if listenAddress != "" {
+			healthz := kredis.HealthzHandler(manager, livenessThreshold)
+			readyz := kredis.ReadyzHandler(manager)
+
+			if elector != nil {
+				healthz = kredis.StandbyHandler(elector, healthz)
+				readyz = kredis.StandbyHandler(elector, readyz)
+			}
+
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", manager.Metrics)
+			mux.Handle("/healthz", healthz)
+			mux.Handle("/readyz", readyz)
+
+			go serveHTTP(ctx, logger, listenAddress, mux)
+		}
+
+		run := func(ctx context.Context) {
+			manager.Run(ctx, discoverer)
+		}
+
+		if elector != nil {
+			elector.Run(ctx, run)
+		} else {
+			run(ctx)
+		}
+
 		return nil
 	},
 }
 
+func newLeaderElector(logger log.Logger) (*kredis.LeaderElector, error) {
+	client, err := kredis.NewInClusterKubernetesClient()
+
+	if err != nil {
+		return nil, fmt.Errorf("leader election: %s", err)
+	}
+
+	namespace := leaderElectionNamespace
+
+	if namespace == "" {
+		if namespace, err = kredis.InClusterNamespace(); err != nil {
+			return nil, fmt.Errorf("leader election: %s", err)
+		}
+	}
+
+	identity := leaderElectionIdentity
+
+	if identity == "" {
+		if identity, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("leader election: %s", err)
+		}
+	}
+
+	return &kredis.LeaderElector{
+		Lock: &kredis.KubernetesLeaseLock{
+			Client:    client,
+			Namespace: namespace,
+			Name:      leaderElectionName,
+		},
+		Identity:      identity,
+		LeaseDuration: leaderElectionLeaseDuration,
+		RetryPeriod:   leaderElectionRetryPeriod,
+		Logger:        logger,
+	}, nil
+}
+
+func parseMasterGroups(args []string) ([]kredis.MasterGroup, error) {
+	masterGroups := make([]kredis.MasterGroup, len(args))
+
+	for i, arg := range args {
+		masterGroup, err := kredis.ParseMasterGroup(arg)
+
+		if err != nil {
+			return nil, fmt.Errorf("parsing argument %d: %s", i, err)
+		}
+
+		masterGroups[i] = masterGroup
+	}
+
+	if len(masterGroups) == 0 {
+		return nil, errors.New("no master groups specified - refusing to run")
+	}
+
+	return masterGroups, nil
+}
+
+func newPool() (*kredis.Pool, error) {
+	username, password, err := loadCredentials()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var tlsConfig *tls.Config
+
+	if useTLS {
+		if tlsConfig, err = kredis.NewTLSConfig(tlsCAFile, tlsCertFile, tlsKeyFile, tlsServerName, tlsSkipVerify); err != nil {
+			return nil, err
+		}
+	}
+
+	return &kredis.Pool{
+		IdleTimeout:              time.Second * 90,
+		MaxActive:                10,
+		MaxIdle:                  2,
+		ConnectTimeout:           connectTimeout,
+		ReadTimeout:              readTimeout,
+		WriteTimeout:             writeTimeout,
+		Username:                 username,
+		Password:                 password,
+		TLSConfig:                tlsConfig,
+		HealthCheckPeriod:        healthCheckPeriod,
+		CircuitBreakerThreshold:  circuitBreakerThreshold,
+		CircuitBreakerBackoff:    circuitBreakerBackoff,
+		CircuitBreakerMaxBackoff: circuitBreakerMaxBackoff,
+	}, nil
+}
+
+// loadCredentials returns the Redis credentials.
+//
+// Flags take precedence over the password file, which takes precedence over
+// the KREDIS_USERNAME and KREDIS_PASSWORD environment variables.
+func loadCredentials() (string, string, error) {
+	user, pass := username, password
+
+	if user == "" {
+		user = os.Getenv("KREDIS_USERNAME")
+	}
+
+	if pass == "" && passwordFile != "" {
+		data, err := ioutil.ReadFile(passwordFile)
+
+		if err != nil {
+			return "", "", fmt.Errorf("reading password file: %s", err)
+		}
+
+		pass = strings.TrimRight(string(data), "\r\n")
+	}
+
+	if pass == "" {
+		pass = os.Getenv("KREDIS_PASSWORD")
+	}
+
+	if user != "" && pass == "" {
+		return "", "", errors.New("a password is required when a username is specified")
+	}
+
+	return user, pass, nil
+}
+
+func newManager(logger log.Logger, pool *kredis.Pool) (*kredis.Manager, error) {
+	command, err := kredis.ParseTopologyCommand(topologyCommand)
+
+	if err != nil {
+		return nil, err
+	}
+
+	automaticFailover, err := kredis.ParseFailoverPolicy(failoverPolicy)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed master is usually unreachable, which stops every sync cycle
+	// before the failover phase unless unreachable instances are allowed.
+	if automaticFailover != "" && !allowUnreachable {
+		return nil, fmt.Errorf("--failover-policy=%s requires --allow-unreachable", failoverPolicy)
+	}
+
+	var slots kredis.HashSlots
+
+	if managedSlots != "" {
+		if slots, err = kredis.ParseHashSlotRanges(managedSlots); err != nil {
+			return nil, err
+		}
+	}
+
+	weightsByInstance := make(map[kredis.RedisInstance]float64, len(weights))
+
+	for _, s := range weights {
+		redisInstance, weight, err := kredis.ParseWeight(s)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if maxMemoryWeights && weight != 0 {
+			return nil, fmt.Errorf("--weight=%s can't be mixed with --maxmemory-weights, which are in bytes: only a weight of 0 is allowed", s)
+		}
+
+		weightsByInstance[redisInstance] = weight
+	}
+
+	pinnedSlots := make(map[kredis.RedisInstance]kredis.HashSlots, len(pins))
+
+	for _, s := range pins {
+		masterGroup, slots, err := kredis.ParseSlotPin(s)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pinnedSlots[masterGroup[0]] = slots
+	}
+
+	return &kredis.Manager{
+		Logger:                 logger,
+		Pool:                   pool,
+		SyncPeriod:             time.Second,
+		WarningPeriodThreshold: time.Second * 10,
+		MaxSlots:               100,
+		ManagedSlots:           slots,
+		SlotsTolerance:         slotsTolerance,
+		Weights:                weightsByInstance,
+		MaxMemoryWeights:       maxMemoryWeights,
+		PinnedSlots:            pinnedSlots,
+		AutomaticFailover:      automaticFailover,
+		FailoverDelay:          failoverDelay,
+		DrainGracePeriod:       drainGracePeriod,
+		ForgetGracePeriod:      forgetGracePeriod,
+		CommandTimeout:         commandTimeout,
+		Concurrency:            concurrency,
+		NodeTimeout:            nodeTimeout,
+		AllowUnreachable:       allowUnreachable,
+		TopologyCommand:        command,
+	}, nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().DurationVar(&connectTimeout, "connect-timeout", time.Second*5, "The timeout for establishing connections to Redis instances.")
+	rootCmd.PersistentFlags().DurationVar(&readTimeout, "read-timeout", time.Second*35, "The timeout for reading replies from Redis instances. Must be longer than slot migrations batches.")
+	rootCmd.PersistentFlags().DurationVar(&writeTimeout, "write-timeout", time.Second*5, "The timeout for writing commands to Redis instances.")
+	rootCmd.PersistentFlags().DurationVar(&commandTimeout, "command-timeout", time.Second*40, "The maximum duration of a single Redis command.")
+	rootCmd.PersistentFlags().DurationVar(&healthCheckPeriod, "health-check-period", time.Minute, "The idle time after which pooled connections are checked with a PING before being reused. Zero disables the checks.")
+	rootCmd.PersistentFlags().IntVar(&circuitBreakerThreshold, "circuit-breaker-threshold", 3, "The number of consecutive connection failures after which a Redis instance is no longer contacted for a while. Zero disables circuit breaking.")
+	rootCmd.PersistentFlags().DurationVar(&circuitBreakerBackoff, "circuit-breaker-backoff", time.Second*5, "The time during which a failing Redis instance is not contacted. Doubles on every subsequent failure.")
+	rootCmd.PersistentFlags().DurationVar(&circuitBreakerMaxBackoff, "circuit-breaker-max-backoff", time.Minute*2, "The maximum time during which a failing Redis instance is not contacted.")
+	rootCmd.PersistentFlags().IntVar(&concurrency, "concurrency", 8, "The maximum number of Redis instances queried in parallel when building the cluster snapshot.")
+	rootCmd.PersistentFlags().DurationVar(&nodeTimeout, "node-timeout", time.Second*5, "The maximum time spent querying a single Redis instance when building the cluster snapshot.")
+	rootCmd.PersistentFlags().BoolVar(&allowUnreachable, "allow-unreachable", false, "Keep reconciling in degraded mode when some Redis instances are unreachable, instead of stopping.")
+	rootCmd.PersistentFlags().StringVar(&managedSlots, "slots", "", "The slots to manage, as a comma-separated list of slots or slot ranges like 0-8191. Other slots are left untouched. Defaults to all slots.")
+	rootCmd.PersistentFlags().IntVar(&slotsTolerance, "slots-tolerance", 0, "The number of slots a master may own above or below its share before slots are migrated to rebalance the cluster.")
+	rootCmd.PersistentFlags().StringSliceVar(&weights, "weight", nil, "The weight of a master group in the slots distribution, as instance=weight where instance is any member of the group. Can be repeated. A weight of 0 drains the group. Only a weight of 0 is allowed with --maxmemory-weights.")
+	rootCmd.PersistentFlags().BoolVar(&maxMemoryWeights, "maxmemory-weights", false, "Weight master groups without an explicit weight by the maxmemory setting of their master, in bytes.")
+	rootCmd.PersistentFlags().StringArrayVar(&pins, "pin", nil, "Slots pinned to a master group, as group=instance,...;slots=range,... Can be repeated. The group only gets its pinned slots.")
+	rootCmd.PersistentFlags().StringVar(&failoverPolicy, "failover-policy", "none", "How to replace masters that a majority of masters flag as failed, when Redis doesn't. One of: none, force, takeover. Requires --allow-unreachable, as failed masters are usually unreachable.")
+	rootCmd.PersistentFlags().DurationVar(&failoverDelay, "failover-delay", time.Second*30, "How long a master must stay failed before it is replaced.")
+	rootCmd.PersistentFlags().DurationVar(&drainGracePeriod, "drain-grace-period", time.Minute, "How long a master must stay out of every master group before its slots are migrated away.")
+	rootCmd.PersistentFlags().DurationVar(&forgetGracePeriod, "forget-grace-period", time.Second*30, "How long a node must stay unknown before it is forgotten by the cluster.")
+	rootCmd.PersistentFlags().StringVar(&topologyCommand, "topology-command", "auto", "The command the slots and roles of nodes are read from. CLUSTER NODES is always read too, for the node flags and migrations. One of: auto, nodes, shards, slots.")
+	rootCmd.PersistentFlags().StringVar(&username, "username", "", "The Redis ACL user to authenticate as. Defaults to the KREDIS_USERNAME environment variable.")
+	rootCmd.PersistentFlags().StringVar(&password, "password", "", "The Redis password. Prefer --password-file or the KREDIS_PASSWORD environment variable, as flags are visible to other processes.")
+	rootCmd.PersistentFlags().StringVar(&passwordFile, "password-file", "", "A file containing the Redis password, like a mounted secret.")
+	rootCmd.PersistentFlags().BoolVar(&useTLS, "tls", false, "Connect to Redis instances using TLS.")
+	rootCmd.PersistentFlags().StringVar(&tlsCAFile, "tls-ca-file", "", "The CA bundle used to verify Redis instances certificates. Defaults to the system certificates.")
+	rootCmd.PersistentFlags().StringVar(&tlsCertFile, "tls-cert-file", "", "The client certificate to present to Redis instances.")
+	rootCmd.PersistentFlags().StringVar(&tlsKeyFile, "tls-key-file", "", "The key of the client certificate.")
+	rootCmd.PersistentFlags().StringVar(&tlsServerName, "tls-server-name", "", "The server name expected in Redis instances certificates. Defaults to the hostname of each instance.")
+	rootCmd.PersistentFlags().BoolVar(&tlsSkipVerify, "tls-skip-verify", false, "Don't verify Redis instances certificates. Only meant for testing.")
+	rootCmd.Flags().StringVar(&listenAddress, "listen-address", "", "The address to serve HTTP metrics and health probes on. Disabled if empty.")
+	rootCmd.Flags().DurationVar(&livenessThreshold, "liveness-threshold", time.Second*30, "The maximum time without a sync cycle or cluster operation before the liveness probe fails.")
+	rootCmd.Flags().BoolVar(&leaderElection, "leader-election", false, "Only manage the cluster while holding a Kubernetes lease, so that several instances can run.")
+	rootCmd.Flags().StringVar(&leaderElectionNamespace, "leader-election-namespace", "", "The namespace of the leader election lease. Defaults to the namespace of the pod.")
+	rootCmd.Flags().StringVar(&leaderElectionName, "leader-election-name", "kredis", "The name of the leader election lease.")
+	rootCmd.Flags().StringVar(&leaderElectionIdentity, "leader-election-identity", "", "The identity of this instance in the leader election. Defaults to the hostname.")
+	rootCmd.Flags().DurationVar(&leaderElectionLeaseDuration, "leader-election-lease-duration", time.Second*15, "The duration of the leader election lease.")
+	rootCmd.Flags().DurationVar(&leaderElectionRetryPeriod, "leader-election-retry-period", time.Second*2, "The period at which the leader election lease is acquired or renewed.")
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ereOn/kredis/pkg/kredis"
+	"github.com/go-kit/kit/log"
+	"github.com/spf13/cobra"
+)
+
+var planOutput string
+
+var planCmd = &cobra.Command{
+	Use:   "plan [master-group...]",
+	Short: "Print the operations required to converge the cluster, without executing them.",
+	Long: `Print the operations required to converge the cluster, without executing them.
+
+The plan is computed from a single snapshot of the cluster, so the operations
+that wait for a grace period (failovers, drains and forgets) are printed as if
+it had already elapsed. The run command only executes them once the condition
+lasted for --failover-delay, --drain-grace-period or --forget-grace-period.`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		discoverer, err := newDiscoverer(args)
+
+		if err != nil {
+			return err
+		}
+
+		var printOperations func(io.Writer, []kredis.Operation) error
+		var cli redisCLI
+
+		switch planOutput {
+		case "table":
+			printOperations = printOperationsTable
+		case "json":
+			printOperations = printOperationsJSON
+		case "redis-cli":
+			printOperations = func(w io.Writer, operations []kredis.Operation) error {
+				return printOperationsRedisCLI(w, cli, operations)
+			}
+		default:
+			return fmt.Errorf("unknown output format \"%s\"", planOutput)
+		}
+
+		cmd.SilenceUsage = true
+
+		logger := log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+
+		pool, err := newPool()
+
+		if err != nil {
+			return err
+		}
+
+		defer pool.Close()
+
+		cli = newRedisCLI(pool)
+
+		manager, err := newManager(logger, pool)
+
+		if err != nil {
+			return err
+		}
+
+		// A single snapshot can't tell how long a condition lasted: planning
+		// as if every grace period had elapsed shows what run would do.
+		manager.FailoverDelay = 0
+		manager.DrainGracePeriod = 0
+		manager.ForgetGracePeriod = 0
+
+		ctx, cancel := WithCancelOnInterupt(context.Background())
+		defer cancel()
+
+		masterGroups, err := discoverer.Discover(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		db, err := manager.BuildDatabase(ctx, masterGroups)
+
+		if err != nil {
+			return err
+		}
+
+		return printOperations(cmd.OutOrStdout(), db.GetOperations())
+	},
+}
+
+func init() {
+	planCmd.Flags().StringVarP(&planOutput, "output", "o", "table", "The output format. One of: table, json, redis-cli.")
+	rootCmd.AddCommand(planCmd)
+}
+
+func printOperationsTable(w io.Writer, operations []kredis.Operation) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "OPERATION\tSTATE\tDETAILS")
+
+	for _, operation := range operations {
+		var details []string
+		keyvals := operation.Describe()
+
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			details = append(details, fmt.Sprintf("%v=%v", keyvals[i], keyvals[i+1]))
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", operation.Name(), operation.State(), strings.Join(details, " "))
+	}
+
+	return tw.Flush()
+}
+
+func printOperationsJSON(w io.Writer, operations []kredis.Operation) error {
+	type jsonOperation struct {
+		Type      string           `json:"type"`
+		Operation kredis.Operation `json:"operation"`
+	}
+
+	items := make([]jsonOperation, len(operations))
+
+	for i, operation := range operations {
+		items[i] = jsonOperation{
+			Type:      operation.Name(),
+			Operation: operation,
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(items)
+}
+
+// redisCLI prints redis-cli commands that connect to Redis instances like the
+// pool does.
+//
+// The password is never printed: redis-cli reads it from the REDISCLI_AUTH
+// environment variable instead.
+type redisCLI struct {
+	username string
+	password bool
+	options  []string
+}
+
+func newRedisCLI(pool *kredis.Pool) redisCLI {
+	cli := redisCLI{
+		username: pool.Username,
+		password: pool.Password != "",
+	}
+
+	if cli.username != "" {
+		cli.options = append(cli.options, "--user", cli.username)
+	}
+
+	if pool.TLSConfig != nil {
+		cli.options = append(cli.options, "--tls")
+
+		for _, option := range []struct{ name, value string }{
+			{"--cacert", tlsCAFile},
+			{"--cert", tlsCertFile},
+			{"--key", tlsKeyFile},
+			{"--sni", tlsServerName},
+		} {
+			if option.value != "" {
+				cli.options = append(cli.options, option.name, option.value)
+			}
+		}
+
+		if tlsSkipVerify {
+			cli.options = append(cli.options, "--insecure")
+		}
+	}
+
+	return cli
+}
+
+func (c redisCLI) command(redisInstance kredis.RedisInstance, args ...interface{}) string {
+	s := fmt.Sprintf("redis-cli -h %s -p %s", redisInstance.Hostname, redisInstance.Port)
+
+	for _, option := range c.options {
+		s += " " + option
+	}
+
+	for _, arg := range args {
+		s += fmt.Sprintf(" %v", arg)
+	}
+
+	return s
+}
+
+// migrateAuth returns the MIGRATE arguments that authenticate against the
+// destination instance.
+func (c redisCLI) migrateAuth() []interface{} {
+	switch {
+	case !c.password:
+		return nil
+	case c.username != "":
+		return []interface{}{"AUTH2", c.username, `"$REDISCLI_AUTH"`}
+	default:
+		return []interface{}{"AUTH", `"$REDISCLI_AUTH"`}
+	}
+}
+
+func resolveHostname(hostname string) string {
+	if ipAddresses, err := net.LookupIP(hostname); err == nil && len(ipAddresses) > 0 {
+		return ipAddresses[0].String()
+	}
+
+	return hostname
+}
+
+func printOperationsRedisCLI(w io.Writer, cli redisCLI, operations []kredis.Operation) error {
+	if cli.password {
+		if _, err := fmt.Fprintln(w, "# The password is read from the REDISCLI_AUTH environment variable."); err != nil {
+			return err
+		}
+	}
+
+	for _, operation := range operations {
+		var lines []string
+
+		switch operation := operation.(type) {
+		case kredis.FailoverOperation:
+			args := []interface{}{"CLUSTER", "FAILOVER"}
+
+			if operation.Mode != "" {
+				args = append(args, strings.ToUpper(string(operation.Mode)))
+			}
+
+			lines = append(lines, cli.command(operation.Target, args...))
+		case kredis.MeetOperation:
+			lines = append(lines, cli.command(operation.Target, "CLUSTER", "MEET", resolveHostname(operation.Other.Hostname), operation.Other.Port))
+		case kredis.ForgetOperation:
+			lines = append(lines, cli.command(operation.Target, "CLUSTER", "FORGET", operation.NodeID))
+		case kredis.ReplicateOperation:
+			lines = append(lines, cli.command(operation.Target, "CLUSTER", "REPLICATE", operation.MasterID))
+		case kredis.AddSlotsOperation:
+			args := []interface{}{"CLUSTER", "ADDSLOTS"}
+
+			for _, slot := range operation.Slots {
+				args = append(args, slot)
+			}
+
+			lines = append(lines, cli.command(operation.Target, args...))
+		case kredis.MigrateSlotOperation:
+			migrateArgs := []interface{}{"MIGRATE", operation.Destination.Hostname, operation.Destination.Port, `""`, 0, int(kredis.MigrateTimeout / time.Millisecond), "REPLACE"}
+			migrateArgs = append(append(migrateArgs, cli.migrateAuth()...), "KEYS")
+
+			lines = append(
+				lines,
+				cli.command(operation.Destination, "CLUSTER", "SETSLOT", operation.Slot, "IMPORTING", operation.SourceID),
+				cli.command(operation.Source, "CLUSTER", "SETSLOT", operation.Slot, "MIGRATING", operation.DestinationID),
+				fmt.Sprintf(
+					"# Repeat until no keys are left: %s | xargs %s",
+					cli.command(operation.Source, "CLUSTER", "GETKEYSINSLOT", operation.Slot, 10000),
+					cli.command(operation.Source, migrateArgs...),
+				),
+				cli.command(operation.Destination, "CLUSTER", "SETSLOT", operation.Slot, "NODE", operation.DestinationID),
+				cli.command(operation.Source, "CLUSTER", "SETSLOT", operation.Slot, "NODE", operation.DestinationID),
+			)
+		case kredis.StabilizeSlotOperation:
+			lines = append(lines, cli.command(operation.Target, "CLUSTER", "SETSLOT", operation.Slot, "STABLE"))
+		default:
+			lines = append(lines, fmt.Sprintf("# No redis-cli equivalent for %s operation: %v", operation.Name(), operation.Describe()))
+		}
+
+		for _, line := range lines {
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
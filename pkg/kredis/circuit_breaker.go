@@ -0,0 +1,100 @@
+package kredis
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A CircuitOpenError is returned when a Redis instance is not contacted
+// because its circuit is open.
+type CircuitOpenError struct {
+	RedisInstance RedisInstance
+	Until         time.Time
+}
+
+// The error message doesn't mention the reopening time so that consecutive
+// errors get aggregated by the error feed.
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit open for %s after consecutive failures", e.RedisInstance)
+}
+
+// A CircuitBreaker tracks the consecutive failures of a resource.
+//
+// Once Threshold consecutive failures are recorded, the circuit opens for
+// Backoff. While it is open, the resource should not be used. Every failure
+// after the circuit reopens doubles the backoff, up to MaxBackoff, while a
+// single success closes the circuit.
+//
+// A zero Threshold disables the circuit breaker.
+type CircuitBreaker struct {
+	Threshold  int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	lock       sync.Mutex
+	failures   int
+	openUntil  time.Time
+	timeFunc   func() time.Time
+}
+
+func (b *CircuitBreaker) init() {
+	if b.timeFunc == nil {
+		b.timeFunc = func() time.Time { return time.Now().UTC() }
+	}
+}
+
+// OpenUntil returns the time until which the circuit is open, if it is open.
+func (b *CircuitBreaker) OpenUntil() (time.Time, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.init()
+
+	if b.openUntil.IsZero() || !b.timeFunc().Before(b.openUntil) {
+		return time.Time{}, false
+	}
+
+	return b.openUntil, true
+}
+
+// Success records a success, which closes the circuit.
+func (b *CircuitBreaker) Success() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+// Failure records a failure, which opens the circuit once the threshold is
+// reached.
+func (b *CircuitBreaker) Failure() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.init()
+
+	if b.Threshold <= 0 {
+		return
+	}
+
+	b.failures++
+
+	if b.failures < b.Threshold {
+		return
+	}
+
+	backoff := b.Backoff
+
+	// Limiting the doublings prevents overflows when MaxBackoff is zero.
+	for i := b.Threshold; i < b.failures && i-b.Threshold < 32; i++ {
+		backoff *= 2
+
+		if b.MaxBackoff > 0 && backoff >= b.MaxBackoff {
+			backoff = b.MaxBackoff
+			break
+		}
+	}
+
+	b.openUntil = b.timeFunc().Add(backoff)
+}
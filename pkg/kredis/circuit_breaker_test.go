@@ -0,0 +1,59 @@
+package kredis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now().UTC()
+	breaker := &CircuitBreaker{
+		Threshold:  2,
+		Backoff:    time.Second,
+		MaxBackoff: time.Second * 3,
+		timeFunc:   func() time.Time { return now },
+	}
+
+	assertOpenUntil := func(expected time.Time) {
+		t.Helper()
+
+		until, open := breaker.OpenUntil()
+
+		if open != !expected.IsZero() || !until.Equal(expected) {
+			t.Errorf("expected the circuit to be open until %s but got: %s (%t)", expected, until, open)
+		}
+	}
+
+	breaker.Failure()
+	assertOpenUntil(time.Time{})
+
+	breaker.Failure()
+	assertOpenUntil(now.Add(time.Second))
+
+	now = now.Add(time.Second)
+	assertOpenUntil(time.Time{})
+
+	breaker.Failure()
+	assertOpenUntil(now.Add(time.Second * 2))
+
+	breaker.Failure()
+	assertOpenUntil(now.Add(time.Second * 3))
+
+	breaker.Success()
+	assertOpenUntil(time.Time{})
+
+	breaker.Failure()
+	assertOpenUntil(time.Time{})
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	breaker := &CircuitBreaker{}
+
+	for i := 0; i < 10; i++ {
+		breaker.Failure()
+	}
+
+	if _, open := breaker.OpenUntil(); open {
+		t.Error("expected the circuit to be closed")
+	}
+}
@@ -0,0 +1,120 @@
+package kredis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// errConnAbandoned is returned when a connection is used after one of its
+// commands was abandoned because its context expired.
+var errConnAbandoned = errors.New("connection abandoned after a context expiration")
+
+type contextConnResult struct {
+	reply interface{}
+	err   error
+}
+
+// A contextConn is a Redis connection whose commands honor a context and a
+// per-command timeout.
+//
+// redigo connections can't be interrupted, so a command whose context expires
+// is abandoned: it keeps running in the background until the connection read
+// or write timeouts kick in, and the underlying connection is closed as soon
+// as it completes.
+type contextConn struct {
+	conn    redis.Conn
+	ctx     context.Context
+	timeout time.Duration
+	pending chan contextConnResult
+}
+
+func newContextConn(ctx context.Context, conn redis.Conn, timeout time.Duration) redis.Conn {
+	return &contextConn{
+		conn:    conn,
+		ctx:     ctx,
+		timeout: timeout,
+	}
+}
+
+func (c *contextConn) run(f func() (interface{}, error)) (interface{}, error) {
+	if c.pending != nil {
+		return nil, errConnAbandoned
+	}
+
+	ctx := c.ctx
+
+	if c.timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make(chan contextConnResult, 1)
+
+	go func() {
+		reply, err := f()
+		results <- contextConnResult{reply: reply, err: err}
+	}()
+
+	select {
+	case result := <-results:
+		return result.reply, result.err
+	case <-ctx.Done():
+		c.pending = results
+		return nil, ctx.Err()
+	}
+}
+
+func (c *contextConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return c.run(func() (interface{}, error) {
+		return c.conn.Do(commandName, args...)
+	})
+}
+
+func (c *contextConn) Send(commandName string, args ...interface{}) error {
+	_, err := c.run(func() (interface{}, error) {
+		return nil, c.conn.Send(commandName, args...)
+	})
+
+	return err
+}
+
+func (c *contextConn) Flush() error {
+	_, err := c.run(func() (interface{}, error) {
+		return nil, c.conn.Flush()
+	})
+
+	return err
+}
+
+func (c *contextConn) Receive() (interface{}, error) {
+	return c.run(c.conn.Receive)
+}
+
+func (c *contextConn) Err() error {
+	if c.pending != nil {
+		return errConnAbandoned
+	}
+
+	return c.conn.Err()
+}
+
+func (c *contextConn) Close() error {
+	if c.pending != nil {
+		go func() {
+			<-c.pending
+			c.conn.Close()
+		}()
+
+		return nil
+	}
+
+	return c.conn.Close()
+}
@@ -0,0 +1,117 @@
+package kredis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type blockingConn struct {
+	unblock chan struct{}
+	closed  chan struct{}
+}
+
+func newBlockingConn() *blockingConn {
+	return &blockingConn{
+		unblock: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (c *blockingConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	<-c.unblock
+	return "OK", nil
+}
+
+func (c *blockingConn) Send(commandName string, args ...interface{}) error {
+	<-c.unblock
+	return nil
+}
+
+func (c *blockingConn) Flush() error {
+	<-c.unblock
+	return nil
+}
+
+func (c *blockingConn) Receive() (interface{}, error) {
+	<-c.unblock
+	return "OK", nil
+}
+
+func (c *blockingConn) Err() error { return nil }
+
+func (c *blockingConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestContextConn(t *testing.T) {
+	conn := newBlockingConn()
+	close(conn.unblock)
+	cconn := newContextConn(context.Background(), conn, time.Second)
+
+	reply, err := cconn.Do("PING")
+
+	if err != nil {
+		t.Errorf("expected no error but got: %s", err)
+	}
+
+	if reply != "OK" {
+		t.Errorf("expected \"OK\" but got: %v", reply)
+	}
+
+	if err = cconn.Close(); err != nil {
+		t.Errorf("expected no error but got: %s", err)
+	}
+
+	select {
+	case <-conn.closed:
+	default:
+		t.Error("expected the connection to be closed")
+	}
+}
+
+func TestContextConnTimeout(t *testing.T) {
+	conn := newBlockingConn()
+	cconn := newContextConn(context.Background(), conn, time.Millisecond)
+
+	if _, err := cconn.Do("PING"); err != context.DeadlineExceeded {
+		t.Errorf("expected %s but got: %v", context.DeadlineExceeded, err)
+	}
+
+	if err := cconn.Err(); err != errConnAbandoned {
+		t.Errorf("expected %s but got: %v", errConnAbandoned, err)
+	}
+
+	if err := cconn.Send("PING"); err != errConnAbandoned {
+		t.Errorf("expected %s but got: %v", errConnAbandoned, err)
+	}
+
+	cconn.Close()
+
+	select {
+	case <-conn.closed:
+		t.Error("expected the connection not to be closed while a command is pending")
+	default:
+	}
+
+	close(conn.unblock)
+
+	select {
+	case <-conn.closed:
+	case <-time.After(time.Second):
+		t.Error("expected the connection to be closed once the pending command completed")
+	}
+}
+
+func TestContextConnCanceled(t *testing.T) {
+	conn := newBlockingConn()
+	close(conn.unblock)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cconn := newContextConn(ctx, conn, 0)
+
+	if _, err := cconn.Do("PING"); err != context.Canceled {
+		t.Errorf("expected %s but got: %v", context.Canceled, err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strconv"
+	"time"
 )
 
 // SlotsCount represents the maximum number of slots that can be shared by a cluster.
@@ -13,6 +15,26 @@ const SlotsCount = 16384
 var AllSlots = NewHashSlotsFromRange(0, SlotsCount-1, 1)
 
 // Database represents a cluster database.
+//
+// ManagedSlots are the slots spread across masters, in proportion to the
+// weight of their master group. A master is only rebalanced when the number
+// of slots it owns differs from its share by more than SlotsTolerance.
+//
+// Weights are keyed by any member of a master group, and master groups without
+// a weight get a weight of 1. A master group with a weight of 0 is drained of
+// all its slots.
+//
+// PinnedSlots are also keyed by any member of a master group. A master group
+// with pinned slots owns exactly those, and the other managed slots are spread
+// across the other master groups.
+//
+// If AutomaticFailover is set, failed masters are replaced by one of their
+// replicas using that mode, once they have been failed for FailoverDelay.
+// Masters that are not part of any master group are drained of their slots
+// once they have been unknown for DrainGracePeriod, and nodes that are not
+// part of any master group are forgotten once they have been unknown for
+// ForgetGracePeriod. These durations are measured across databases by
+// Timeline: without one, they must be zero for the operations to be planned.
 type Database struct {
 	masterGroups                []MasterGroup
 	masterGroupsByRedisInstance map[RedisInstance]MasterGroup
@@ -23,7 +45,18 @@ type Database struct {
 	slavesByID                  map[ClusterNodeID][]ClusterNodeID
 	connections                 []Connection
 	slotsByID                   map[ClusterNodeID]HashSlots
+	migratingByID               map[ClusterNodeID]map[int]ClusterNodeID
+	importingByID               map[ClusterNodeID]map[int]ClusterNodeID
+	unreachable                 map[RedisInstance]error
 	ManagedSlots                HashSlots
+	SlotsTolerance              int
+	Weights                     map[RedisInstance]float64
+	PinnedSlots                 map[RedisInstance]HashSlots
+	AutomaticFailover           FailoverMode
+	FailoverDelay               time.Duration
+	DrainGracePeriod            time.Duration
+	ForgetGracePeriod           time.Duration
+	Timeline                    *Timeline
 }
 
 // A Connection represents a link from one node to the other.
@@ -66,6 +99,10 @@ func (d *Database) Feed(redisInstance RedisInstance, nodes ClusterNodes) error {
 		return fmt.Errorf("%s is not part of a registered master group", redisInstance)
 	}
 
+	if _, ok := d.unreachable[redisInstance]; ok {
+		return fmt.Errorf("refusing to feed %s as it was marked as unreachable", redisInstance)
+	}
+
 	selfNode, err := nodes.Self()
 
 	if err != nil {
@@ -108,6 +145,22 @@ func (d *Database) Feed(redisInstance RedisInstance, nodes ClusterNodes) error {
 		}
 	}
 
+	if len(selfNode.Migrating) > 0 {
+		if d.migratingByID == nil {
+			d.migratingByID = make(map[ClusterNodeID]map[int]ClusterNodeID)
+		}
+
+		d.migratingByID[selfNode.ID] = selfNode.Migrating
+	}
+
+	if len(selfNode.Importing) > 0 {
+		if d.importingByID == nil {
+			d.importingByID = make(map[ClusterNodeID]map[int]ClusterNodeID)
+		}
+
+		d.importingByID[selfNode.ID] = selfNode.Importing
+	}
+
 	d.redisInstancesByID[selfNode.ID] = redisInstance
 	d.idByRedisInstance[redisInstance] = selfNode.ID
 	d.nodesByID[selfNode.ID] = nodes
@@ -115,6 +168,72 @@ func (d *Database) Feed(redisInstance RedisInstance, nodes ClusterNodes) error {
 	return nil
 }
 
+// MarkUnreachable records that the specified Redis instance could not be
+// queried.
+//
+// Unreachable instances put the database in degraded mode, in which only the
+// operations that are safe given the missing information are planned.
+func (d *Database) MarkUnreachable(redisInstance RedisInstance, reason error) error {
+	if _, ok := d.masterGroupsByRedisInstance[redisInstance]; !ok {
+		return fmt.Errorf("%s is not part of a registered master group", redisInstance)
+	}
+
+	if _, ok := d.idByRedisInstance[redisInstance]; ok {
+		return fmt.Errorf("refusing to mark %s as unreachable as it was already fed", redisInstance)
+	}
+
+	if d.unreachable == nil {
+		d.unreachable = make(map[RedisInstance]error)
+	}
+
+	d.unreachable[redisInstance] = reason
+
+	return nil
+}
+
+// IsDegraded checks whether some Redis instances were marked as unreachable.
+func (d *Database) IsDegraded() bool {
+	return len(d.unreachable) > 0
+}
+
+// GetUnreachableErrors returns the reasons why Redis instances were marked as
+// unreachable, in registration order.
+func (d *Database) GetUnreachableErrors() (errs []error) {
+	for _, masterGroup := range d.masterGroups {
+		for _, redisInstance := range masterGroup {
+			if err, ok := d.unreachable[redisInstance]; ok {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return
+}
+
+// GetShortCircuited returns the Redis instances that were marked as
+// unreachable because their circuit was open, in registration order.
+func (d *Database) GetShortCircuited() (redisInstances []RedisInstance) {
+	for _, masterGroup := range d.masterGroups {
+		for _, redisInstance := range masterGroup {
+			if _, ok := d.unreachable[redisInstance].(*CircuitOpenError); ok {
+				redisInstances = append(redisInstances, redisInstance)
+			}
+		}
+	}
+
+	return
+}
+
+func (d *Database) isGroupReachable(masterGroup MasterGroup) bool {
+	for _, redisInstance := range masterGroup {
+		if _, ok := d.unreachable[redisInstance]; ok {
+			return false
+		}
+	}
+
+	return true
+}
+
 func getClusterNodeIDsIndex(id ClusterNodeID, ids []ClusterNodeID) int {
 	return sort.Search(len(ids), func(i int) bool {
 		return ids[i] >= id
@@ -127,6 +246,19 @@ func inClusterNodeIDs(id ClusterNodeID, ids []ClusterNodeID) bool {
 	return i < len(ids) && ids[i] == id
 }
 
+// getKnownIDs returns the sorted IDs of the nodes the database was fed with.
+func (d *Database) getKnownIDs() []ClusterNodeID {
+	ids := make([]ClusterNodeID, 0, len(d.nodesByID))
+
+	for id := range d.nodesByID {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids
+}
+
 // IsMaster checks if the specified cluster ID is a master node.
 func (d *Database) IsMaster(id ClusterNodeID) bool {
 	return inClusterNodeIDs(id, d.masters)
@@ -211,46 +343,17 @@ func (d *Database) addSlave(masterID, id ClusterNodeID) error {
 	return nil
 }
 
-// Operation represents a cluster operation.
-type Operation interface{}
-
-// A MeetOperation indicates that a node must meet another.
-type MeetOperation struct {
-	Target RedisInstance
-	Other  RedisInstance
-}
-
-// A ForgetOperation indicates that a node must be forgotten.
-type ForgetOperation struct {
-	Target RedisInstance
-	NodeID ClusterNodeID
-}
-
-// A ReplicateOperation indicates that a node must replicate another.
-type ReplicateOperation struct {
-	Target   RedisInstance
-	Master   RedisInstance
-	MasterID ClusterNodeID
-}
-
-// AddSlotsOperation adds slots to a given instance.
-type AddSlotsOperation struct {
-	Target RedisInstance
-	Slots  HashSlots
-}
-
-// MigrateSlotOperation adds slots to a given instance.
-type MigrateSlotOperation struct {
-	Source        RedisInstance
-	SourceID      ClusterNodeID
-	Destination   RedisInstance
-	DestinationID ClusterNodeID
-	Slot          int
-}
-
 func (d *Database) getExpectedConnections(masterGroup MasterGroup) (connections []Connection) {
 	for i, a := range masterGroup {
 		for j, b := range masterGroup {
+			if _, ok := d.unreachable[a]; ok {
+				continue
+			}
+
+			if _, ok := d.unreachable[b]; ok {
+				continue
+			}
+
 			if i != j {
 				connections = append(connections, Connection{
 					From: d.idByRedisInstance[a],
@@ -282,10 +385,31 @@ func (d *Database) getMissingConnections(expectedConnections []Connection) (conn
 	return
 }
 
+func (d *Database) missingConnectionReason(connection Connection, scope string, masterGroup MasterGroup) Reason {
+	return Reason{
+		Code: ReasonMissingConnection,
+		Evidence: map[string]string{
+			"node":         connection.From.String(),
+			"unknown-node": connection.To.String(),
+			"scope":        scope,
+			"group":        masterGroup.String(),
+		},
+	}
+}
+
 // GetOperations returns the operations that need to be performed in order for
 // the cluster to meet an acceptable state.
+//
+// The conditions gated by a grace period are observed on every call, even
+// when an earlier phase has operations to run.
 func (d *Database) GetOperations() (operations []Operation) {
-	if operations = d.GetMeshOperations(); len(operations) != 0 {
+	d.observeGracePeriods()
+
+	if operations = d.GetFailoverOperations(); len(operations) != 0 {
+		return
+	}
+
+	if operations = append(operations, d.GetMeshOperations()...); len(operations) != 0 {
 		return
 	}
 
@@ -293,18 +417,220 @@ func (d *Database) GetOperations() (operations []Operation) {
 		return
 	}
 
+	if operations = append(operations, d.GetMigrationRepairOperations()...); len(operations) != 0 {
+		return
+	}
+
 	operations = append(operations, d.GetAssignationOperations()...)
 	return
 }
 
+// getSelf returns the node the specified node reported for itself.
+func (d *Database) getSelf(id ClusterNodeID) (ClusterNode, bool) {
+	node, err := d.nodesByID[id].Self()
+
+	return node, err == nil
+}
+
+// isFlagged checks whether any fed node flags the specified node with any of
+// the specified flags.
+func (d *Database) isFlagged(id ClusterNodeID, flags ...ClusterNodeFlag) bool {
+	for _, nodes := range d.nodesByID {
+		for _, node := range nodes {
+			if node.ID != id {
+				continue
+			}
+
+			for _, flag := range flags {
+				if node.Flags[flag] {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// getFailReports returns how many of the other known masters flag the
+// specified master as failed, and how many other known masters there are.
+func (d *Database) getFailReports(masterID ClusterNodeID) (reporters int, voters int) {
+	for _, id := range d.masters {
+		if id == masterID || !d.isKnownMaster(id) {
+			continue
+		}
+
+		voters++
+
+		for _, node := range d.nodesByID[id] {
+			if node.ID == masterID && node.Flags[FlagFail] {
+				reporters++
+				break
+			}
+		}
+	}
+
+	return
+}
+
+// getFailoverCandidate returns the healthiest reachable replica of the
+// specified master, in the same master group.
+//
+// Replicas flagged `nofailover` are never picked. Replicas with the highest
+// replication offset are preferred, then the ones that are not loading their
+// data set, then the first ones in their master group.
+func (d *Database) getFailoverCandidate(masterID ClusterNodeID) (candidate ClusterNode, ok bool) {
+	masterRedisInstance, masterKnown := d.redisInstancesByID[masterID]
+	rank := func(node ClusterNode) int {
+		redisInstance := d.redisInstancesByID[node.ID]
+
+		for i, member := range d.masterGroupsByRedisInstance[redisInstance] {
+			if member == redisInstance {
+				return i
+			}
+		}
+
+		return -1
+	}
+	better := func(node, other ClusterNode) bool {
+		if node.ReplicationOffset != other.ReplicationOffset {
+			return node.ReplicationOffset > other.ReplicationOffset
+		}
+
+		if (node.Health == HealthLoading) != (other.Health == HealthLoading) {
+			return other.Health == HealthLoading
+		}
+
+		return rank(node) < rank(other)
+	}
+
+	for _, id := range d.slavesByID[masterID] {
+		node, fed := d.getSelf(id)
+
+		if !fed || node.MasterID != masterID || node.Flags[FlagNoFailover] || node.Health == HealthFailed || d.isFlagged(id, FlagFail, FlagProbableFail) {
+			continue
+		}
+
+		masterGroup := d.masterGroupsByRedisInstance[d.redisInstancesByID[id]]
+
+		if masterKnown {
+			if masterGroup.String() != d.masterGroupsByRedisInstance[masterRedisInstance].String() {
+				continue
+			}
+		} else if d.hasFedMaster(masterGroup) {
+			// The failed master was never fed, so it can only belong to a
+			// master group that lost its master.
+			continue
+		}
+
+		if !ok || better(node, candidate) {
+			candidate = node
+			ok = true
+		}
+	}
+
+	return
+}
+
+// hasFedMaster checks whether a member of the specified master group was fed
+// and is a master.
+func (d *Database) hasFedMaster(masterGroup MasterGroup) bool {
+	for _, redisInstance := range masterGroup {
+		if id, ok := d.idByRedisInstance[redisInstance]; ok && d.IsMaster(id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetFailoverOperations returns the failover operations that need to be
+// performed for the masters the cluster agrees are failed, when Redis didn't
+// promote one of their replicas by itself.
+//
+// A master is failed if a majority of the other known masters flag it as
+// `fail`. It is replaced by its healthiest reachable replica in the same
+// master group, once it has been failed for FailoverDelay.
+//
+// Failover operations are planned in degraded mode too, as failed masters are
+// usually unreachable.
+func (d *Database) GetFailoverOperations() (operations []Operation) {
+	if d.AutomaticFailover == "" {
+		return
+	}
+
+	for _, masterID := range d.getFailedMasters() {
+		reporters, voters := d.getFailReports(masterID)
+		candidate, ok := d.getFailoverCandidate(masterID)
+
+		if !ok {
+			continue
+		}
+
+		operations = append(operations, FailoverOperation{
+			Target:   d.redisInstancesByID[candidate.ID],
+			MasterID: masterID,
+			Mode:     d.AutomaticFailover,
+			Reason: Reason{
+				Code: ReasonFailedMaster,
+				Evidence: map[string]string{
+					"master":             masterID.String(),
+					"fail-reports":       fmt.Sprintf("%d/%d", reporters, voters),
+					"replica":            candidate.ID.String(),
+					"replication-offset": strconv.FormatInt(candidate.ReplicationOffset, 10),
+				},
+			},
+		})
+	}
+
+	return
+}
+
+// getFailedMasters returns the masters that a majority of the other known
+// masters have flagged as failed for FailoverDelay.
+func (d *Database) getFailedMasters() (ids []ClusterNodeID) {
+	for _, masterID := range d.masters {
+		reporters, voters := d.getFailReports(masterID)
+
+		if reporters*2 <= voters {
+			continue
+		}
+
+		var elapsed time.Duration
+
+		if d.Timeline != nil {
+			elapsed = d.Timeline.Observe("failover:" + masterID.String())
+		}
+
+		if elapsed >= d.FailoverDelay {
+			ids = append(ids, masterID)
+		}
+	}
+
+	return
+}
+
 // GetMeshOperations returns the mesh operations that need to be performed for
 // all the members of the cluster to know about each other.
+//
+// Nodes that are not part of any master group are forgotten by every node
+// once they have been unknown for ForgetGracePeriod, unless a node still sees
+// them as owning slots or in handshake.
+//
+// In degraded mode, unreachable instances are left out of the mesh and no
+// node is forgotten.
 func (d *Database) GetMeshOperations() (operations []Operation) {
 	// Cluster mesh.
 	var leaderGroup MasterGroup
 
 	for _, masterGroup := range d.masterGroups {
-		leaderGroup = append(leaderGroup, masterGroup[0])
+		for _, redisInstance := range masterGroup {
+			if _, ok := d.unreachable[redisInstance]; !ok {
+				leaderGroup = append(leaderGroup, redisInstance)
+				break
+			}
+		}
+
 		expectedConnections := d.getExpectedConnections(masterGroup)
 		missingConnections := d.getMissingConnections(expectedConnections)
 
@@ -312,6 +638,7 @@ func (d *Database) GetMeshOperations() (operations []Operation) {
 			operations = append(operations, MeetOperation{
 				Target: d.redisInstancesByID[connection.From],
 				Other:  d.redisInstancesByID[connection.To],
+				Reason: d.missingConnectionReason(connection, "group", masterGroup),
 			})
 		}
 	}
@@ -323,16 +650,34 @@ func (d *Database) GetMeshOperations() (operations []Operation) {
 		operations = append(operations, MeetOperation{
 			Target: d.redisInstancesByID[connection.From],
 			Other:  d.redisInstancesByID[connection.To],
+			Reason: d.missingConnectionReason(connection, "leaders", leaderGroup),
 		})
 	}
 
-	for nodeID, nodes := range d.nodesByID {
-		for _, node := range nodes {
-			if _, ok := d.nodesByID[node.ID]; !ok {
-				operations = append(operations, ForgetOperation{
-					Target: d.redisInstancesByID[nodeID],
-					NodeID: node.ID,
-				})
+	// In degraded mode, an unknown node might just be an unreachable instance:
+	// forgetting it would be unsafe.
+	if d.IsDegraded() {
+		return
+	}
+
+	for _, id := range d.getForgottenNodes(d.getForeignNodes()) {
+		// Forgetting a node everywhere at once prevents the nodes that still
+		// know about it from spreading it again through gossip.
+		for _, nodeID := range d.getKnownIDs() {
+			for _, node := range d.nodesByID[nodeID] {
+				if node.ID == id {
+					operations = append(operations, ForgetOperation{
+						Target: d.redisInstancesByID[nodeID],
+						NodeID: id,
+						Reason: Reason{
+							Code: ReasonUnknownNode,
+							Evidence: map[string]string{
+								"node":         nodeID.String(),
+								"unknown-node": id.String(),
+							},
+						},
+					})
+				}
 			}
 		}
 	}
@@ -340,12 +685,76 @@ func (d *Database) GetMeshOperations() (operations []Operation) {
 	return
 }
 
+// getForgottenNodes returns the specified foreign nodes that have been unknown
+// for ForgetGracePeriod, sorted by ID.
+func (d *Database) getForgottenNodes(foreignNodes map[ClusterNodeID]ClusterNode) (ids []ClusterNodeID) {
+	foreignIDs := make([]ClusterNodeID, 0, len(foreignNodes))
+
+	for id := range foreignNodes {
+		foreignIDs = append(foreignIDs, id)
+	}
+
+	sort.Slice(foreignIDs, func(i, j int) bool { return foreignIDs[i] < foreignIDs[j] })
+
+	for _, id := range foreignIDs {
+		// Nodes in handshake have a temporary ID and might be joining, while
+		// slot owners are drained by the assignation first, as forgetting
+		// them would lose their slots and data.
+		if d.isFlagged(id, FlagHandshake) || d.ownsSlots(id) {
+			continue
+		}
+
+		var elapsed time.Duration
+
+		if d.Timeline != nil {
+			elapsed = d.Timeline.Observe("forget:" + id.String())
+		}
+
+		if elapsed >= d.ForgetGracePeriod {
+			ids = append(ids, id)
+		}
+	}
+
+	return
+}
+
+// observeGracePeriods observes every condition that gates operations behind a
+// grace period, so that the grace periods don't restart whenever an earlier
+// phase has operations to run.
+//
+// In degraded mode, unknown nodes can't be told apart from unreachable ones:
+// the drain and forget conditions observed so far are kept as they are.
+func (d *Database) observeGracePeriods() {
+	if d.Timeline == nil {
+		return
+	}
+
+	d.getFailedMasters()
+
+	if d.IsDegraded() {
+		d.Timeline.Keep("drain:", "forget:")
+		return
+	}
+
+	foreignNodes := d.getForeignNodes()
+	d.getForgottenNodes(foreignNodes)
+	d.getDrainedMasters(foreignNodes)
+}
+
 // GetReplicationOperations returns the replication operations that need to be
 // performed for all the members of the cluster to know about their respective
 // roles.
+//
+// In degraded mode, master groups with unreachable members are left as-is.
 func (d *Database) GetReplicationOperations() (operations []Operation) {
 	// Master/slave assignations. Only performed once the mesh is established.
 	for _, masterGroup := range d.masterGroups {
+		// The roles of a group can't be decided without seeing all its
+		// members.
+		if !d.isGroupReachable(masterGroup) {
+			continue
+		}
+
 		var masters []RedisInstance
 		var slaves []RedisInstance
 
@@ -369,6 +778,17 @@ func (d *Database) GetReplicationOperations() (operations []Operation) {
 			var master RedisInstance = masters[0]
 
 			replicators := masters
+			reasons := make([]Reason, len(masters))
+
+			for i := range masters {
+				reasons[i] = Reason{
+					Code: ReasonMultipleMasters,
+					Evidence: map[string]string{
+						"group":   masterGroup.String(),
+						"masters": MasterGroup(masters).String(),
+					},
+				}
+			}
 
 			for _, slave := range slaves {
 				nodeID := d.GetMasterOf(d.idByRedisInstance[slave])
@@ -380,16 +800,25 @@ func (d *Database) GetReplicationOperations() (operations []Operation) {
 					} else {
 						// The slave has an unknown master. We must also reassign him.
 						replicators = append(replicators, slave)
+						reasons = append(reasons, Reason{
+							Code: ReasonUnknownMaster,
+							Evidence: map[string]string{
+								"group":     masterGroup.String(),
+								"node":      d.idByRedisInstance[slave].String(),
+								"master-id": nodeID.String(),
+							},
+						})
 					}
 				}
 			}
 
-			for _, slave := range replicators {
+			for i, slave := range replicators {
 				if slave != master {
 					operations = append(operations, ReplicateOperation{
 						Target:   slave,
 						Master:   masters[0],
 						MasterID: d.idByRedisInstance[masters[0]],
+						Reason:   reasons[i],
 					})
 				}
 			}
@@ -399,30 +828,248 @@ func (d *Database) GetReplicationOperations() (operations []Operation) {
 	return
 }
 
-// GetAssignationOperations returns the assignation operations that need to be
-// performed for all the members of the cluster to know which slots they are
-// responsible for.
-func (d *Database) GetAssignationOperations() (operations []Operation) {
-	addSlotsByID := map[ClusterNodeID]HashSlots{}
+// isKnownMaster checks whether the specified node is a master that was fed
+// to the database.
+func (d *Database) isKnownMaster(id ClusterNodeID) bool {
+	_, ok := d.redisInstancesByID[id]
+
+	return ok && d.IsMaster(id)
+}
+
+// getForeignNodes returns the nodes that fed nodes know about but that were
+// not fed themselves, as reported by the first fed node that lists them.
+//
+// Outside of degraded mode, these are the nodes that are not part of any
+// master group, like the members of a removed master group.
+func (d *Database) getForeignNodes() map[ClusterNodeID]ClusterNode {
+	foreignNodes := map[ClusterNodeID]ClusterNode{}
+
+	for _, id := range d.getKnownIDs() {
+		for _, node := range d.nodesByID[id] {
+			if _, ok := d.nodesByID[node.ID]; ok {
+				continue
+			}
+
+			if _, ok := foreignNodes[node.ID]; !ok {
+				foreignNodes[node.ID] = node
+			}
+		}
+	}
+
+	return foreignNodes
+}
+
+// getRedisInstance returns the Redis instance of the specified node: the one
+// it was fed from or, for one of the specified foreign nodes, its cluster
+// address.
+func (d *Database) getRedisInstance(id ClusterNodeID, foreignNodes map[ClusterNodeID]ClusterNode) RedisInstance {
+	if redisInstance, ok := d.redisInstancesByID[id]; ok {
+		return redisInstance
+	}
+
+	node, ok := foreignNodes[id]
+
+	if !ok {
+		return RedisInstance{}
+	}
+
+	hostname := node.Address.Hostname
+
+	if node.Address.IP != nil {
+		hostname = node.Address.IP.String()
+	}
+
+	return RedisInstance{Hostname: hostname, Port: node.Address.Port}
+}
+
+// ownsSlots checks whether any fed node sees the specified node as the owner
+// of slots.
+func (d *Database) ownsSlots(id ClusterNodeID) bool {
+	for _, nodes := range d.nodesByID {
+		for _, node := range nodes {
+			if node.ID == id && len(node.Slots) > 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// getSlotOwners returns the masters owning each slot.
+//
+// The slots of the specified foreign masters are taken from the view of the
+// first fed node that lists them, while fed masters are authoritative for
+// their own slots.
+func (d *Database) getSlotOwners(foreignNodes map[ClusterNodeID]ClusterNode) map[int]ClusterNodeID {
 	idsBySlot := map[int]ClusterNodeID{}
 
+	for id, node := range foreignNodes {
+		if node.Flags[FlagMaster] {
+			for _, slot := range node.Slots {
+				idsBySlot[slot] = id
+			}
+		}
+	}
+
 	for _, nodeID := range d.masters {
 		for _, slot := range d.slotsByID[nodeID] {
 			idsBySlot[slot] = nodeID
 		}
 	}
 
-	for i, slot := range d.ManagedSlots {
-		nodeID := d.masters[(i*len(d.masters))/len(d.ManagedSlots)]
+	return idsBySlot
+}
+
+// GetMigrationRepairOperations returns the operations that need to be
+// performed for slot migrations left half-finished, typically by a crash, to
+// be either finished or rolled back.
+//
+// A migration is finished if the slot is still owned by its source and its
+// destination is a known master. Otherwise, the nodes it left in a migrating
+// or importing state are stabilized.
+//
+// No migration repair operations are returned in degraded mode.
+func (d *Database) GetMigrationRepairOperations() (operations []Operation) {
+	// In degraded mode, rolling back a migration could strand the keys that
+	// were already moved to an unreachable node.
+	if d.IsDegraded() {
+		return
+	}
+
+	type migration struct {
+		slot          int
+		sourceID      ClusterNodeID
+		destinationID ClusterNodeID
+	}
+
+	idsBySlot := d.getSlotOwners(d.getForeignNodes())
+	finished := map[migration]bool{}
+
+	finish := func(m migration, evidence map[string]string) bool {
+		if idsBySlot[m.slot] != m.sourceID || !d.isKnownMaster(m.sourceID) || !d.isKnownMaster(m.destinationID) || m.sourceID == m.destinationID {
+			return false
+		}
+
+		if !finished[m] {
+			finished[m] = true
+			operations = append(operations, MigrateSlotOperation{
+				Source:        d.redisInstancesByID[m.sourceID],
+				SourceID:      m.sourceID,
+				Destination:   d.redisInstancesByID[m.destinationID],
+				DestinationID: m.destinationID,
+				Slot:          m.slot,
+				Repair:        true,
+				Reason:        Reason{Code: ReasonOpenMigration, Evidence: evidence},
+			})
+		}
+
+		return true
+	}
+
+	stabilize := func(id ClusterNodeID, slot int, evidence map[string]string) {
+		operations = append(operations, StabilizeSlotOperation{
+			Target: d.redisInstancesByID[id],
+			Slot:   slot,
+			Reason: Reason{Code: ReasonDanglingMigration, Evidence: evidence},
+		})
+	}
+
+	for _, id := range d.getKnownIDs() {
+		migrating := d.migratingByID[id]
+
+		for _, slot := range sortedSlotKeys(migrating) {
+			evidence := map[string]string{
+				"node":         id.String(),
+				"slot":         strconv.Itoa(slot),
+				"migrating-to": migrating[slot].String(),
+				"owner":        idsBySlot[slot].String(),
+			}
+
+			if !finish(migration{slot: slot, sourceID: id, destinationID: migrating[slot]}, evidence) {
+				stabilize(id, slot, evidence)
+			}
+		}
+	}
+
+	for _, id := range d.getKnownIDs() {
+		importing := d.importingByID[id]
+
+		for _, slot := range sortedSlotKeys(importing) {
+			evidence := map[string]string{
+				"node":           id.String(),
+				"slot":           strconv.Itoa(slot),
+				"importing-from": importing[slot].String(),
+				"owner":          idsBySlot[slot].String(),
+			}
+
+			if !finish(migration{slot: slot, sourceID: importing[slot], destinationID: id}, evidence) {
+				stabilize(id, slot, evidence)
+			}
+		}
+	}
+
+	return
+}
+
+// GetAssignationOperations returns the assignation operations that need to be
+// performed for all the members of the cluster to know which slots they are
+// responsible for.
+//
+// Slots are spread across masters according to their weight while keeping
+// their current owners wherever possible: only the masters whose slot count
+// differs from their share by more than SlotsTolerance are rebalanced, and
+// unassigned slots go to the masters that have the fewest. Masters that are
+// not part of any master group are drained of all their slots once they have
+// been unknown for DrainGracePeriod, and their slots are left untouched until
+// then.
+//
+// Pinned slots always go to the master of their master group. Slots that
+// can't be assigned, because all the masters they could go to have a weight of
+// 0, are left untouched.
+//
+// No assignation operations are returned in degraded mode.
+func (d *Database) GetAssignationOperations() (operations []Operation) {
+	// In degraded mode, some masters and slot owners are unknown: any
+	// assignation or migration could conflict with them.
+	if d.IsDegraded() || len(d.masters) == 0 {
+		return
+	}
+
+	foreignNodes := d.getForeignNodes()
+	idsBySlot := d.getSlotOwners(foreignNodes)
+	assignees := d.getSlotAssignees(idsBySlot, d.getDrainedMasters(foreignNodes))
+	addSlotsByID := map[ClusterNodeID]HashSlots{}
+
+	for _, slot := range d.ManagedSlots {
+		nodeID, ok := assignees[slot]
+
+		if !ok {
+			continue
+		}
 
 		if ownerID, ok := idsBySlot[slot]; ok {
 			if ownerID != nodeID {
+				code := ReasonMisassignedSlot
+
+				if _, fed := d.redisInstancesByID[ownerID]; !fed {
+					code = ReasonRemovedOwner
+				}
+
 				operations = append(operations, MigrateSlotOperation{
-					Source:        d.redisInstancesByID[ownerID],
+					Source:        d.getRedisInstance(ownerID, foreignNodes),
 					SourceID:      ownerID,
 					Destination:   d.redisInstancesByID[nodeID],
 					DestinationID: nodeID,
 					Slot:          slot,
+					Reason: Reason{
+						Code: code,
+						Evidence: map[string]string{
+							"slot":     strconv.Itoa(slot),
+							"owner":    ownerID.String(),
+							"assignee": nodeID.String(),
+						},
+					},
 				})
 			}
 		} else {
@@ -430,12 +1077,360 @@ func (d *Database) GetAssignationOperations() (operations []Operation) {
 		}
 	}
 
-	for nodeID, slots := range addSlotsByID {
-		operations = append(operations, AddSlotsOperation{
-			Target: d.redisInstancesByID[nodeID],
-			Slots:  slots,
-		})
+	for _, nodeID := range d.masters {
+		if slots, ok := addSlotsByID[nodeID]; ok {
+			operations = append(operations, AddSlotsOperation{
+				Target: d.redisInstancesByID[nodeID],
+				Slots:  slots,
+				Reason: Reason{
+					Code: ReasonUnassignedSlots,
+					Evidence: map[string]string{
+						"slots":    slots.String(),
+						"assignee": nodeID.String(),
+					},
+				},
+			})
+		}
 	}
 
 	return
 }
+
+// getWeight returns the weight of the master group of the specified master.
+func (d *Database) getWeight(id ClusterNodeID) float64 {
+	redisInstance, ok := d.redisInstancesByID[id]
+
+	if !ok {
+		return 1
+	}
+
+	for _, member := range d.masterGroupsByRedisInstance[redisInstance] {
+		if weight, ok := d.Weights[member]; ok {
+			return weight
+		}
+	}
+
+	return 1
+}
+
+// getSlotTargets returns the number of slots each master should own, in
+// proportion to its weight.
+//
+// The slots left over by rounding go to the masters with the largest
+// fractional shares and then to the ones that own the most slots, so that
+// they don't cause migrations.
+func getSlotTargets(slotsCount int, masters []ClusterNodeID, counts map[ClusterNodeID]int, weights map[ClusterNodeID]float64) map[ClusterNodeID]int {
+	total := 0.0
+
+	for _, nodeID := range masters {
+		total += weights[nodeID]
+	}
+
+	if total <= 0 {
+		return nil
+	}
+
+	targets := make(map[ClusterNodeID]int, len(masters))
+	fractions := make(map[ClusterNodeID]float64, len(masters))
+	remainder := slotsCount
+
+	for _, nodeID := range masters {
+		share := float64(slotsCount) * weights[nodeID] / total
+		targets[nodeID] = int(share)
+		fractions[nodeID] = share - float64(targets[nodeID])
+		remainder -= targets[nodeID]
+	}
+
+	masters = append([]ClusterNodeID(nil), masters...)
+	sort.SliceStable(masters, func(i, j int) bool {
+		if fractions[masters[i]] != fractions[masters[j]] {
+			return fractions[masters[i]] > fractions[masters[j]]
+		}
+
+		return counts[masters[i]] > counts[masters[j]]
+	})
+
+	for _, nodeID := range masters[:remainder] {
+		targets[nodeID]++
+	}
+
+	return targets
+}
+
+// getPinnedSlots returns the slots pinned to the master group of the specified
+// master, along with the member of the master group they are keyed by.
+func (d *Database) getPinnedSlots(id ClusterNodeID) (RedisInstance, HashSlots, bool) {
+	redisInstance, ok := d.redisInstancesByID[id]
+
+	if !ok {
+		return RedisInstance{}, nil, false
+	}
+
+	for _, member := range d.masterGroupsByRedisInstance[redisInstance] {
+		if slots, ok := d.PinnedSlots[member]; ok {
+			return member, slots, true
+		}
+	}
+
+	return RedisInstance{}, nil, false
+}
+
+// CheckPinnedSlots checks that the pinned slots belong to registered master
+// groups, are managed, and don't overlap.
+func (d *Database) CheckPinnedSlots() error {
+	managed := make(map[int]bool, len(d.ManagedSlots))
+
+	for _, slot := range d.ManagedSlots {
+		managed[slot] = true
+	}
+
+	redisInstances := make([]RedisInstance, 0, len(d.PinnedSlots))
+
+	for redisInstance := range d.PinnedSlots {
+		redisInstances = append(redisInstances, redisInstance)
+	}
+
+	sort.Slice(redisInstances, func(i, j int) bool { return redisInstances[i].String() < redisInstances[j].String() })
+
+	pinnedBySlot := map[int]RedisInstance{}
+	pinnedByGroup := map[string]RedisInstance{}
+
+	for _, redisInstance := range redisInstances {
+		masterGroup, ok := d.masterGroupsByRedisInstance[redisInstance]
+
+		if !ok {
+			return fmt.Errorf("slots are pinned to %s, which is not part of a registered master group", redisInstance)
+		}
+
+		if other, ok := pinnedByGroup[masterGroup.String()]; ok {
+			return fmt.Errorf("slots are pinned to both %s and %s, which are part of the same master group %s", other, redisInstance, masterGroup)
+		}
+
+		pinnedByGroup[masterGroup.String()] = redisInstance
+
+		for _, slot := range d.PinnedSlots[redisInstance] {
+			if !managed[slot] {
+				return fmt.Errorf("slot %d is pinned to %s but is not managed", slot, redisInstance)
+			}
+
+			if other, ok := pinnedBySlot[slot]; ok {
+				return fmt.Errorf("slot %d is pinned to both %s and %s", slot, other, redisInstance)
+			}
+
+			pinnedBySlot[slot] = redisInstance
+		}
+	}
+
+	return nil
+}
+
+// getDrainedMasters returns the specified foreign masters that own slots and
+// have been unknown for DrainGracePeriod.
+//
+// A master that is missing from the master groups might only be missing for a
+// while, like when discovery skips it during a rolling restart: draining it
+// right away would migrate all its slots back and forth.
+func (d *Database) getDrainedMasters(foreignNodes map[ClusterNodeID]ClusterNode) map[ClusterNodeID]bool {
+	drained := map[ClusterNodeID]bool{}
+
+	for id, node := range foreignNodes {
+		if !node.Flags[FlagMaster] || !d.ownsSlots(id) {
+			continue
+		}
+
+		var elapsed time.Duration
+
+		if d.Timeline != nil {
+			elapsed = d.Timeline.Observe("drain:" + id.String())
+		}
+
+		if elapsed >= d.DrainGracePeriod {
+			drained[id] = true
+		}
+	}
+
+	return drained
+}
+
+// getSlotAssignees returns the master each managed slot should be assigned
+// to. Slots that can't be assigned, and the slots of foreign masters that are
+// not drained yet, are left out.
+func (d *Database) getSlotAssignees(idsBySlot map[int]ClusterNodeID, drained map[ClusterNodeID]bool) map[int]ClusterNodeID {
+	assignees := make(map[int]ClusterNodeID, len(d.ManagedSlots))
+	pinnedMasters := map[ClusterNodeID]bool{}
+	pinnedGroups := map[RedisInstance]bool{}
+	held := func(slot int) bool {
+		ownerID, ok := idsBySlot[slot]
+
+		if !ok || drained[ownerID] {
+			return false
+		}
+
+		_, fed := d.redisInstancesByID[ownerID]
+
+		return !fed
+	}
+
+	// Only the first master of a master group with pinned slots gets them,
+	// but none of its masters get any other slot.
+	for _, nodeID := range d.masters {
+		redisInstance, slots, ok := d.getPinnedSlots(nodeID)
+
+		if !ok {
+			continue
+		}
+
+		pinnedMasters[nodeID] = true
+
+		if pinnedGroups[redisInstance] {
+			continue
+		}
+
+		pinnedGroups[redisInstance] = true
+
+		for _, slot := range slots {
+			if !held(slot) {
+				assignees[slot] = nodeID
+			}
+		}
+	}
+
+	var slots HashSlots
+	var masters []ClusterNodeID
+
+	for _, slot := range d.ManagedSlots {
+		if _, ok := assignees[slot]; !ok && !held(slot) {
+			slots = append(slots, slot)
+		}
+	}
+
+	// Foreign masters are drained of all the slots they don't hold.
+	for _, nodeID := range d.masters {
+		if !pinnedMasters[nodeID] && d.isKnownMaster(nodeID) {
+			masters = append(masters, nodeID)
+		}
+	}
+
+	for slot, nodeID := range d.balanceSlots(slots, masters, idsBySlot) {
+		assignees[slot] = nodeID
+	}
+
+	return assignees
+}
+
+// balanceSlots returns the master each of the specified slots should be
+// assigned to, or nil if none of the specified masters can be assigned slots.
+func (d *Database) balanceSlots(slots HashSlots, masters []ClusterNodeID, idsBySlot map[int]ClusterNodeID) map[int]ClusterNodeID {
+	assignees := make(map[int]ClusterNodeID, len(slots))
+	slotsByID := map[ClusterNodeID]HashSlots{}
+	isMaster := make(map[ClusterNodeID]bool, len(masters))
+	var pool HashSlots
+
+	for _, nodeID := range masters {
+		isMaster[nodeID] = true
+	}
+
+	for _, slot := range slots {
+		if ownerID, ok := idsBySlot[slot]; ok && isMaster[ownerID] {
+			slotsByID[ownerID] = append(slotsByID[ownerID], slot)
+		} else {
+			pool = append(pool, slot)
+		}
+	}
+
+	counts := make(map[ClusterNodeID]int, len(masters))
+
+	for _, nodeID := range masters {
+		counts[nodeID] = len(slotsByID[nodeID])
+	}
+
+	weights := make(map[ClusterNodeID]float64, len(masters))
+
+	for _, nodeID := range masters {
+		weights[nodeID] = d.getWeight(nodeID)
+	}
+
+	targets := getSlotTargets(len(slots), masters, counts, weights)
+
+	if targets == nil {
+		return nil
+	}
+
+	// Drained masters must give up all their slots, whatever the tolerance.
+	tolerance := func(nodeID ClusterNodeID) int {
+		if weights[nodeID] <= 0 {
+			return 0
+		}
+
+		return d.SlotsTolerance
+	}
+
+	// release gives back the highest slots of a master, so that the
+	// remaining ones stay contiguous.
+	release := func(nodeID ClusterNodeID, n int) {
+		slots := slotsByID[nodeID]
+		pool = append(pool, slots[len(slots)-n:]...)
+		slotsByID[nodeID] = slots[:len(slots)-n]
+		counts[nodeID] -= n
+	}
+
+	need := 0
+
+	for _, nodeID := range masters {
+		if counts[nodeID] > targets[nodeID]+tolerance(nodeID) {
+			release(nodeID, counts[nodeID]-targets[nodeID])
+		} else if counts[nodeID] < targets[nodeID]-tolerance(nodeID) {
+			need += targets[nodeID] - counts[nodeID]
+		}
+	}
+
+	// Masters within tolerance may have to give up their surplus for the
+	// others to reach their share.
+	for len(pool) < need {
+		var richest ClusterNodeID
+
+		for _, nodeID := range masters {
+			if richest == "" || counts[nodeID]-targets[nodeID] > counts[richest]-targets[richest] {
+				richest = nodeID
+			}
+		}
+
+		release(richest, 1)
+	}
+
+	sort.Ints(pool)
+
+	for _, nodeID := range masters {
+		if counts[nodeID] < targets[nodeID]-tolerance(nodeID) {
+			n := targets[nodeID] - counts[nodeID]
+			slotsByID[nodeID] = append(slotsByID[nodeID], pool[:n]...)
+			counts[nodeID] += n
+			pool = pool[n:]
+		}
+	}
+
+	for _, slot := range pool {
+		var poorest ClusterNodeID
+
+		for _, nodeID := range masters {
+			if weights[nodeID] <= 0 {
+				continue
+			}
+
+			if poorest == "" || counts[nodeID]-targets[nodeID] < counts[poorest]-targets[poorest] {
+				poorest = nodeID
+			}
+		}
+
+		slotsByID[poorest] = append(slotsByID[poorest], slot)
+		counts[poorest]++
+	}
+
+	for nodeID, slots := range slotsByID {
+		for _, slot := range slots {
+			assignees[slot] = nodeID
+		}
+	}
+
+	return assignees
+}
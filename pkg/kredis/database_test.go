@@ -1,9 +1,13 @@
 package kredis
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 var (
@@ -65,6 +69,70 @@ func mustParseClusterNodes(s string) ClusterNodes {
 	return nodes
 }
 
+func missingConnectionReason(node, unknownNode ClusterNodeID, scope string, masterGroup MasterGroup) Reason {
+	return Reason{
+		Code: ReasonMissingConnection,
+		Evidence: map[string]string{
+			"node":         node.String(),
+			"unknown-node": unknownNode.String(),
+			"scope":        scope,
+			"group":        masterGroup.String(),
+		},
+	}
+}
+
+func unknownNodeReason(node, unknownNode ClusterNodeID) Reason {
+	return Reason{
+		Code: ReasonUnknownNode,
+		Evidence: map[string]string{
+			"node":         node.String(),
+			"unknown-node": unknownNode.String(),
+		},
+	}
+}
+
+func multipleMastersReason(masterGroup MasterGroup, masters MasterGroup) Reason {
+	return Reason{
+		Code: ReasonMultipleMasters,
+		Evidence: map[string]string{
+			"group":   masterGroup.String(),
+			"masters": masters.String(),
+		},
+	}
+}
+
+func unknownMasterReason(masterGroup MasterGroup, node, masterID ClusterNodeID) Reason {
+	return Reason{
+		Code: ReasonUnknownMaster,
+		Evidence: map[string]string{
+			"group":     masterGroup.String(),
+			"node":      node.String(),
+			"master-id": masterID.String(),
+		},
+	}
+}
+
+func unassignedSlotsReason(assignee ClusterNodeID, slots HashSlots) Reason {
+	return Reason{
+		Code: ReasonUnassignedSlots,
+		Evidence: map[string]string{
+			"slots":    slots.String(),
+			"assignee": assignee.String(),
+		},
+	}
+}
+
+func misassignedSlotReason(slot int, owner, assignee ClusterNodeID) Reason {
+	return Reason{
+		Code: ReasonMisassignedSlot,
+		Evidence: map[string]string{
+			"slot":     strconv.Itoa(slot),
+			"owner":    owner.String(),
+			"assignee": assignee.String(),
+		},
+	}
+}
+
 func compareOperations(expected []Operation, operations []Operation) bool {
 	if len(expected) != len(operations) {
 		return false
@@ -293,26 +361,32 @@ func TestDatabaseGetOperationsMesh(t *testing.T) {
 		MeetOperation{
 			Target: riA,
 			Other:  riB,
+			Reason: missingConnectionReason("a", "b", "group", group),
 		},
 		MeetOperation{
 			Target: riA,
 			Other:  riC,
+			Reason: missingConnectionReason("a", "c", "group", group),
 		},
 		MeetOperation{
 			Target: riB,
 			Other:  riA,
+			Reason: missingConnectionReason("b", "a", "group", group),
 		},
 		MeetOperation{
 			Target: riB,
 			Other:  riC,
+			Reason: missingConnectionReason("b", "c", "group", group),
 		},
 		MeetOperation{
 			Target: riC,
 			Other:  riA,
+			Reason: missingConnectionReason("c", "a", "group", group),
 		},
 		MeetOperation{
 			Target: riC,
 			Other:  riB,
+			Reason: missingConnectionReason("c", "b", "group", group),
 		},
 	}
 
@@ -334,26 +408,32 @@ func TestDatabaseGetOperationsMeshLeaders(t *testing.T) {
 		MeetOperation{
 			Target: riA,
 			Other:  riB,
+			Reason: missingConnectionReason("a", "b", "leaders", group),
 		},
 		MeetOperation{
 			Target: riA,
 			Other:  riC,
+			Reason: missingConnectionReason("a", "c", "leaders", group),
 		},
 		MeetOperation{
 			Target: riB,
 			Other:  riA,
+			Reason: missingConnectionReason("b", "a", "leaders", group),
 		},
 		MeetOperation{
 			Target: riB,
 			Other:  riC,
+			Reason: missingConnectionReason("b", "c", "leaders", group),
 		},
 		MeetOperation{
 			Target: riC,
 			Other:  riA,
+			Reason: missingConnectionReason("c", "a", "leaders", group),
 		},
 		MeetOperation{
 			Target: riC,
 			Other:  riB,
+			Reason: missingConnectionReason("c", "b", "leaders", group),
 		},
 	}
 
@@ -380,10 +460,80 @@ c 1:1@1 master - 0 0 0 connected
 		ForgetOperation{
 			Target: riA,
 			NodeID: "c",
+			Reason: unknownNodeReason("a", "c"),
 		},
 		ForgetOperation{
 			Target: riB,
 			NodeID: "c",
+			Reason: unknownNodeReason("b", "c"),
+		},
+	}
+
+	if !compareOperations(expected, operations) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, operations)
+	}
+}
+
+func TestDatabaseGetOperationsMeshScaleDownDrain(t *testing.T) {
+	database := &Database{ManagedSlots: NewHashSlotsFromRange(0, 3, 1)}
+	database.RegisterGroup(MasterGroup{riA})
+	database.RegisterGroup(MasterGroup{riB})
+	database.Feed(riA, mustParseClusterNodes(`
+a 1:1@1 master,myself - 0 0 0 connected 2
+b 1:1@1 master - 0 0 0 connected 3
+c 10.0.0.3:6379@16379 master - 0 0 0 connected 0-1
+d 10.0.0.4:6379@16379 slave c 0 0 0 connected
+`))
+	database.Feed(riB, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected 2
+b 1:1@1 master,myself - 0 0 0 connected 3
+c 10.0.0.3:6379@16379 master - 0 0 0 connected 0-1
+d 10.0.0.4:6379@16379 slave c 0 0 0 connected
+`))
+
+	// Only the replica of the removed master can be forgotten right away.
+	operations := database.GetMeshOperations()
+	expected := []Operation{
+		ForgetOperation{
+			Target: riA,
+			NodeID: "d",
+			Reason: unknownNodeReason("a", "d"),
+		},
+		ForgetOperation{
+			Target: riB,
+			NodeID: "d",
+			Reason: unknownNodeReason("b", "d"),
+		},
+	}
+
+	if !compareOperations(expected, operations) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, operations)
+	}
+
+	riRemoved := RedisInstance{Hostname: "10.0.0.3", Port: "6379"}
+	operations = database.GetAssignationOperations()
+	expected = []Operation{
+		MigrateSlotOperation{
+			Source:        riRemoved,
+			SourceID:      "c",
+			Destination:   riA,
+			DestinationID: "a",
+			Slot:          0,
+			Reason: Reason{
+				Code:     ReasonRemovedOwner,
+				Evidence: misassignedSlotReason(0, "c", "a").Evidence,
+			},
+		},
+		MigrateSlotOperation{
+			Source:        riRemoved,
+			SourceID:      "c",
+			Destination:   riB,
+			DestinationID: "b",
+			Slot:          1,
+			Reason: Reason{
+				Code:     ReasonRemovedOwner,
+				Evidence: misassignedSlotReason(1, "c", "b").Evidence,
+			},
 		},
 	}
 
@@ -392,6 +542,198 @@ c 1:1@1 master - 0 0 0 connected
 	}
 }
 
+func TestDatabaseGetOperationsAssignationDrainGracePeriod(t *testing.T) {
+	now := time.Now().UTC()
+	timeline := &Timeline{timeFunc: func() time.Time { return now }}
+	riRemoved := RedisInstance{Hostname: "10.0.0.3", Port: "6379"}
+
+	// "c" briefly comes back to the master groups, like after a restart.
+	for i, discovered := range []bool{false, false, true, false, false, false} {
+		database := &Database{ManagedSlots: NewHashSlotsFromRange(0, 3, 1), DrainGracePeriod: time.Second * 10, Timeline: timeline}
+		database.RegisterGroup(MasterGroup{riA})
+		database.RegisterGroup(MasterGroup{riB})
+		database.Feed(riA, mustParseClusterNodes(`
+a 1:1@1 master,myself - 0 0 0 connected 2
+b 1:1@1 master - 0 0 0 connected 3
+c 10.0.0.3:6379@16379 master - 0 0 0 connected 0-1
+`))
+		database.Feed(riB, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected 2
+b 1:1@1 master,myself - 0 0 0 connected 3
+c 10.0.0.3:6379@16379 master - 0 0 0 connected 0-1
+`))
+
+		if discovered {
+			database.RegisterGroup(MasterGroup{riRemoved})
+			database.Feed(riRemoved, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected 2
+b 1:1@1 master - 0 0 0 connected 3
+c 10.0.0.3:6379@16379 master,myself - 0 0 0 connected 0-1
+`))
+		}
+
+		var expected []Operation
+
+		if i == 5 {
+			expected = []Operation{
+				MigrateSlotOperation{
+					Source:        riRemoved,
+					SourceID:      "c",
+					Destination:   riA,
+					DestinationID: "a",
+					Slot:          0,
+					Reason: Reason{
+						Code:     ReasonRemovedOwner,
+						Evidence: misassignedSlotReason(0, "c", "a").Evidence,
+					},
+				},
+				MigrateSlotOperation{
+					Source:        riRemoved,
+					SourceID:      "c",
+					Destination:   riB,
+					DestinationID: "b",
+					Slot:          1,
+					Reason: Reason{
+						Code:     ReasonRemovedOwner,
+						Evidence: misassignedSlotReason(1, "c", "b").Evidence,
+					},
+				},
+			}
+		}
+
+		operations := database.GetAssignationOperations()
+		timeline.Commit()
+		now = now.Add(time.Second * 5)
+
+		if !compareOperations(expected, operations) {
+			t.Errorf("cycle %d: expected:\n%v\ngot:\n%v", i, expected, operations)
+		}
+	}
+}
+
+func TestDatabaseGetOperationsDrainDuringReplication(t *testing.T) {
+	now := time.Now().UTC()
+	timeline := &Timeline{timeFunc: func() time.Time { return now }}
+
+	// "c" stops replicating "b" in the middle of the drain of "x".
+	for i, detached := range []bool{false, true, true, false} {
+		cFlags, cMaster := "slave", "b"
+
+		if detached {
+			cFlags, cMaster = "master", "-"
+		}
+
+		nodes := fmt.Sprintf(`
+a 1:1@1 master - 0 0 0 connected 2
+b 1:1@1 master - 0 0 0 connected 3
+c 1:1@1 %s %s 0 0 0 connected
+x 10.0.0.3:6379@16379 master - 0 0 0 connected 0-1
+`, cFlags, cMaster)
+
+		database := &Database{ManagedSlots: NewHashSlotsFromRange(0, 3, 1), DrainGracePeriod: time.Second * 10, Timeline: timeline}
+		database.RegisterGroup(MasterGroup{riA})
+		database.RegisterGroup(MasterGroup{riB, riC})
+		database.Feed(riA, mustParseClusterNodes(strings.Replace(nodes, "a 1:1@1 master", "a 1:1@1 master,myself", 1)))
+		database.Feed(riB, mustParseClusterNodes(strings.Replace(nodes, "b 1:1@1 master", "b 1:1@1 master,myself", 1)))
+		database.Feed(riC, mustParseClusterNodes(strings.Replace(nodes, "c 1:1@1 "+cFlags, "c 1:1@1 myself,"+cFlags, 1)))
+
+		var names []string
+
+		for _, operation := range database.GetOperations() {
+			names = append(names, operation.Name())
+		}
+
+		timeline.Commit()
+		now = now.Add(time.Second * 5)
+
+		expected := [][]string{nil, {"replicate"}, {"replicate"}, {"migrate-slot", "migrate-slot"}}[i]
+
+		if !reflect.DeepEqual(names, expected) {
+			t.Errorf("cycle %d: expected %v but got %v", i, expected, names)
+		}
+	}
+}
+
+func TestDatabaseGetOperationsMeshHandshake(t *testing.T) {
+	database := &Database{}
+	database.RegisterGroup(MasterGroup{riA})
+	database.RegisterGroup(MasterGroup{riB})
+	database.Feed(riA, mustParseClusterNodes(`
+a 1:1@1 master,myself - 0 0 0 connected
+b 1:1@1 master - 0 0 0 connected
+c 1:1@1 master,handshake - 0 0 0 disconnected
+`))
+	database.Feed(riB, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected
+b 1:1@1 master,myself - 0 0 0 connected
+`))
+
+	if operations := database.GetOperations(); len(operations) != 0 {
+		t.Errorf("expected no operations but got: %v", operations)
+	}
+}
+
+func TestDatabaseGetOperationsMeshForgetGracePeriod(t *testing.T) {
+	now := time.Now().UTC()
+	timeline := &Timeline{timeFunc: func() time.Time { return now }}
+	views := []string{
+		// "c" is unknown to "a" only, then briefly disappears, then is
+		// unknown to both.
+		"c 1:1@1 master,fail - 0 0 0 disconnected",
+		"c 1:1@1 master,fail - 0 0 0 disconnected",
+		"",
+		"c 1:1@1 master,noaddr - 0 0 0 disconnected",
+		"c 1:1@1 master,noaddr - 0 0 0 disconnected",
+		"c 1:1@1 master,noaddr - 0 0 0 disconnected",
+	}
+
+	for i, view := range views {
+		database := &Database{ForgetGracePeriod: time.Second * 10, Timeline: timeline}
+		database.RegisterGroup(MasterGroup{riA})
+		database.RegisterGroup(MasterGroup{riB})
+		database.Feed(riA, mustParseClusterNodes(`
+a 1:1@1 master,myself - 0 0 0 connected
+b 1:1@1 master - 0 0 0 connected
+`+view))
+
+		bView := ""
+
+		if i >= 3 {
+			bView = view
+		}
+
+		database.Feed(riB, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected
+b 1:1@1 master,myself - 0 0 0 connected
+`+bView))
+
+		var expected []Operation
+
+		if i == 5 {
+			expected = []Operation{
+				ForgetOperation{
+					Target: riA,
+					NodeID: "c",
+					Reason: unknownNodeReason("a", "c"),
+				},
+				ForgetOperation{
+					Target: riB,
+					NodeID: "c",
+					Reason: unknownNodeReason("b", "c"),
+				},
+			}
+		}
+
+		operations := database.GetOperations()
+		timeline.Commit()
+		now = now.Add(time.Second * 5)
+
+		if !compareOperations(expected, operations) {
+			t.Errorf("cycle %d: expected:\n%v\ngot:\n%v", i, expected, operations)
+		}
+	}
+}
+
 func TestDatabaseGetOperationsReplication(t *testing.T) {
 	database := &Database{}
 	database.RegisterGroup(group)
@@ -416,11 +758,13 @@ c 1:1@1 master,myself - 0 0 0 connected
 			Target:   riB,
 			Master:   riA,
 			MasterID: "a",
+			Reason:   multipleMastersReason(group, MasterGroup{riA, riB, riC}),
 		},
 		ReplicateOperation{
 			Target:   riC,
 			Master:   riA,
 			MasterID: "a",
+			Reason:   multipleMastersReason(group, MasterGroup{riA, riB, riC}),
 		},
 	}
 
@@ -453,6 +797,7 @@ c 1:1@1 master,myself - 0 0 0 connected
 			Target:   riC,
 			Master:   riB,
 			MasterID: "b",
+			Reason:   multipleMastersReason(group, MasterGroup{riB, riC}),
 		},
 	}
 
@@ -485,11 +830,13 @@ c 1:1@1 master,myself - 0 0 0 connected
 			Target:   riC,
 			Master:   riB,
 			MasterID: "b",
+			Reason:   multipleMastersReason(group, MasterGroup{riB, riC}),
 		},
 		ReplicateOperation{
 			Target:   riA,
 			Master:   riB,
 			MasterID: "b",
+			Reason:   unknownMasterReason(group, "a", "d"),
 		},
 	}
 
@@ -521,6 +868,7 @@ c 1:1@1 slave,myself a 0 0 0 connected
 		AddSlotsOperation{
 			Target: riA,
 			Slots:  NewHashSlotsFromRange(0, SlotsCount-1, 1),
+			Reason: unassignedSlotsReason("a", NewHashSlotsFromRange(0, SlotsCount-1, 1)),
 		},
 	}
 
@@ -546,10 +894,12 @@ b 1:1@1 master,myself - 0 0 0 connected
 		AddSlotsOperation{
 			Target: riA,
 			Slots:  NewHashSlotsFromRange(0, SlotsCount/2-1, 1),
+			Reason: unassignedSlotsReason("a", NewHashSlotsFromRange(0, SlotsCount/2-1, 1)),
 		},
 		AddSlotsOperation{
 			Target: riB,
 			Slots:  NewHashSlotsFromRange(SlotsCount/2, SlotsCount-1, 1),
+			Reason: unassignedSlotsReason("b", NewHashSlotsFromRange(SlotsCount/2, SlotsCount-1, 1)),
 		},
 	}
 
@@ -569,6 +919,179 @@ b 1:1@1 master - 0 0 0 connected 0 6-7
 	database.Feed(riB, mustParseClusterNodes(`
 a 1:1@1 master - 0 0 0 connected 1-2
 b 1:1@1 master,myself - 0 0 0 connected 0 6-7
+`))
+	operations := database.GetOperations()
+	expected := []Operation{
+		AddSlotsOperation{
+			Target: riA,
+			Slots:  NewHashSlotsFromRange(3, 5, 1),
+			Reason: unassignedSlotsReason("a", NewHashSlotsFromRange(3, 5, 1)),
+		},
+		AddSlotsOperation{
+			Target: riB,
+			Slots:  NewHashSlotsFromRange(8, 10, 1),
+			Reason: unassignedSlotsReason("b", NewHashSlotsFromRange(8, 10, 1)),
+		},
+	}
+
+	if !compareOperations(expected, operations) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, operations)
+	}
+}
+
+func TestDatabaseGetOperationsAssignationNewGroup(t *testing.T) {
+	database := &Database{ManagedSlots: NewHashSlotsFromRange(0, 10, 1)}
+	database.RegisterGroup(MasterGroup{riA})
+	database.RegisterGroup(MasterGroup{riB})
+	database.RegisterGroup(MasterGroup{riC})
+	database.Feed(riA, mustParseClusterNodes(`
+a 1:1@1 master,myself - 0 0 0 connected 0-5
+b 1:1@1 master - 0 0 0 connected 6-10
+c 1:1@1 master - 0 0 0 connected
+`))
+	database.Feed(riB, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected 0-5
+b 1:1@1 master,myself - 0 0 0 connected 6-10
+c 1:1@1 master - 0 0 0 connected
+`))
+	database.Feed(riC, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected 0-5
+b 1:1@1 master - 0 0 0 connected 6-10
+c 1:1@1 master,myself - 0 0 0 connected
+`))
+	operations := database.GetOperations()
+	expected := []Operation{
+		MigrateSlotOperation{
+			Source:        riA,
+			SourceID:      "a",
+			Destination:   riC,
+			DestinationID: "c",
+			Slot:          4,
+			Reason:        misassignedSlotReason(4, "a", "c"),
+		},
+		MigrateSlotOperation{
+			Source:        riA,
+			SourceID:      "a",
+			Destination:   riC,
+			DestinationID: "c",
+			Slot:          5,
+			Reason:        misassignedSlotReason(5, "a", "c"),
+		},
+		MigrateSlotOperation{
+			Source:        riB,
+			SourceID:      "b",
+			Destination:   riC,
+			DestinationID: "c",
+			Slot:          10,
+			Reason:        misassignedSlotReason(10, "b", "c"),
+		},
+	}
+
+	if !compareOperations(expected, operations) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, operations)
+	}
+}
+
+func TestDatabaseGetOperationsAssignationTolerance(t *testing.T) {
+	testCases := []struct {
+		Name      string
+		Tolerance int
+		Expected  []Operation
+	}{
+		{
+			Name:      "strict",
+			Tolerance: 0,
+			Expected: []Operation{
+				MigrateSlotOperation{
+					Source:        riA,
+					SourceID:      "a",
+					Destination:   riB,
+					DestinationID: "b",
+					Slot:          6,
+					Reason:        misassignedSlotReason(6, "a", "b"),
+				},
+			},
+		},
+		{
+			Name:      "tolerant",
+			Tolerance: 1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			database := &Database{
+				ManagedSlots:   NewHashSlotsFromRange(0, 10, 1),
+				SlotsTolerance: testCase.Tolerance,
+			}
+			database.RegisterGroup(MasterGroup{riA})
+			database.RegisterGroup(MasterGroup{riB})
+			database.Feed(riA, mustParseClusterNodes(`
+a 1:1@1 master,myself - 0 0 0 connected 0-6
+b 1:1@1 master - 0 0 0 connected 7-10
+`))
+			database.Feed(riB, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected 0-6
+b 1:1@1 master,myself - 0 0 0 connected 7-10
+`))
+			operations := database.GetOperations()
+
+			if !compareOperations(testCase.Expected, operations) {
+				t.Errorf("expected:\n%v\ngot:\n%v", testCase.Expected, operations)
+			}
+		})
+	}
+}
+
+func TestDatabaseGetOperationsAssignationWeights(t *testing.T) {
+	database := &Database{
+		ManagedSlots: NewHashSlotsFromRange(0, 11, 1),
+		Weights:      map[RedisInstance]float64{riA: 2},
+	}
+	database.RegisterGroup(MasterGroup{riA})
+	database.RegisterGroup(MasterGroup{riB})
+	database.Feed(riA, mustParseClusterNodes(`
+a 1:1@1 master,myself - 0 0 0 connected
+b 1:1@1 master - 0 0 0 connected
+`))
+	database.Feed(riB, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected
+b 1:1@1 master,myself - 0 0 0 connected
+`))
+	operations := database.GetOperations()
+	expected := []Operation{
+		AddSlotsOperation{
+			Target: riA,
+			Slots:  NewHashSlotsFromRange(0, 7, 1),
+			Reason: unassignedSlotsReason("a", NewHashSlotsFromRange(0, 7, 1)),
+		},
+		AddSlotsOperation{
+			Target: riB,
+			Slots:  NewHashSlotsFromRange(8, 11, 1),
+			Reason: unassignedSlotsReason("b", NewHashSlotsFromRange(8, 11, 1)),
+		},
+	}
+
+	if !compareOperations(expected, operations) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, operations)
+	}
+}
+
+func TestDatabaseGetOperationsAssignationDrain(t *testing.T) {
+	database := &Database{
+		ManagedSlots:   NewHashSlotsFromRange(0, 3, 1),
+		SlotsTolerance: 2,
+		Weights:        map[RedisInstance]float64{riB: 0},
+	}
+	database.RegisterGroup(MasterGroup{riA})
+	database.RegisterGroup(MasterGroup{riB})
+	database.Feed(riA, mustParseClusterNodes(`
+a 1:1@1 master,myself - 0 0 0 connected 0-1
+b 1:1@1 master - 0 0 0 connected 2
+`))
+	database.Feed(riB, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected 0-1
+b 1:1@1 master,myself - 0 0 0 connected 2
 `))
 	operations := database.GetOperations()
 	expected := []Operation{
@@ -577,15 +1100,160 @@ b 1:1@1 master,myself - 0 0 0 connected 0 6-7
 			SourceID:      "b",
 			Destination:   riA,
 			DestinationID: "a",
+			Slot:          2,
+			Reason:        misassignedSlotReason(2, "b", "a"),
+		},
+		AddSlotsOperation{
+			Target: riA,
+			Slots:  HashSlots{3},
+			Reason: unassignedSlotsReason("a", HashSlots{3}),
+		},
+	}
+
+	if !compareOperations(expected, operations) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, operations)
+	}
+}
+
+func TestDatabaseGetOperationsAssignationAllDrained(t *testing.T) {
+	database := &Database{
+		ManagedSlots: NewHashSlotsFromRange(0, 3, 1),
+		Weights:      map[RedisInstance]float64{riA: 0},
+	}
+	database.RegisterGroup(MasterGroup{riA})
+	database.Feed(riA, mustParseClusterNodes(`
+a 1:1@1 master,myself - 0 0 0 connected 0-1
+`))
+
+	if operations := database.GetOperations(); len(operations) != 0 {
+		t.Errorf("expected no operations but got: %v", operations)
+	}
+}
+
+func TestDatabaseGetOperationsAssignationPinned(t *testing.T) {
+	database := &Database{
+		ManagedSlots: NewHashSlotsFromRange(0, 9, 1),
+		PinnedSlots:  map[RedisInstance]HashSlots{riC: {0, 1}},
+	}
+	database.RegisterGroup(MasterGroup{riA})
+	database.RegisterGroup(MasterGroup{riB})
+	database.RegisterGroup(MasterGroup{riC})
+	database.Feed(riA, mustParseClusterNodes(`
+a 1:1@1 master,myself - 0 0 0 connected 0-4
+b 1:1@1 master - 0 0 0 connected 5-9
+c 1:1@1 master - 0 0 0 connected
+`))
+	database.Feed(riB, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected 0-4
+b 1:1@1 master,myself - 0 0 0 connected 5-9
+c 1:1@1 master - 0 0 0 connected
+`))
+	database.Feed(riC, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected 0-4
+b 1:1@1 master - 0 0 0 connected 5-9
+c 1:1@1 master,myself - 0 0 0 connected
+`))
+
+	if err := database.CheckPinnedSlots(); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	operations := database.GetOperations()
+	expected := []Operation{
+		MigrateSlotOperation{
+			Source:        riA,
+			SourceID:      "a",
+			Destination:   riC,
+			DestinationID: "c",
 			Slot:          0,
+			Reason:        misassignedSlotReason(0, "a", "c"),
+		},
+		MigrateSlotOperation{
+			Source:        riA,
+			SourceID:      "a",
+			Destination:   riC,
+			DestinationID: "c",
+			Slot:          1,
+			Reason:        misassignedSlotReason(1, "a", "c"),
+		},
+		MigrateSlotOperation{
+			Source:        riB,
+			SourceID:      "b",
+			Destination:   riA,
+			DestinationID: "a",
+			Slot:          9,
+			Reason:        misassignedSlotReason(9, "b", "a"),
+		},
+	}
+
+	if !compareOperations(expected, operations) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, operations)
+	}
+}
+
+func TestDatabaseCheckPinnedSlotsFailure(t *testing.T) {
+	riD := RedisInstance{Hostname: "d"}
+	testCases := []struct {
+		Name        string
+		PinnedSlots map[RedisInstance]HashSlots
+	}{
+		{
+			Name:        "unregistered",
+			PinnedSlots: map[RedisInstance]HashSlots{riD: {0}},
+		},
+		{
+			Name:        "unmanaged",
+			PinnedSlots: map[RedisInstance]HashSlots{riA: {11}},
 		},
+		{
+			Name:        "overlap",
+			PinnedSlots: map[RedisInstance]HashSlots{riA: {0, 1}, riC: {1, 2}},
+		},
+		{
+			Name:        "same-group",
+			PinnedSlots: map[RedisInstance]HashSlots{riA: {0}, riB: {1}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			database := &Database{
+				ManagedSlots: NewHashSlotsFromRange(0, 10, 1),
+				PinnedSlots:  testCase.PinnedSlots,
+			}
+			database.RegisterGroup(MasterGroup{riA, riB})
+			database.RegisterGroup(MasterGroup{riC})
+
+			if err := database.CheckPinnedSlots(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestDatabaseGetOperationsAssignationPartial(t *testing.T) {
+	database := &Database{ManagedSlots: NewHashSlotsFromRange(0, 4, 1)}
+	database.RegisterGroup(MasterGroup{riA})
+	database.RegisterGroup(MasterGroup{riB})
+	database.Feed(riA, mustParseClusterNodes(`
+a 1:1@1 master,myself - 0 0 0 connected 5-9
+b 1:1@1 master - 0 0 0 connected
+`))
+	database.Feed(riB, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected 5-9
+b 1:1@1 master,myself - 0 0 0 connected
+`))
+	operations := database.GetOperations()
+	expected := []Operation{
 		AddSlotsOperation{
 			Target: riA,
-			Slots:  NewHashSlotsFromRange(3, 5, 1),
+			Slots:  NewHashSlotsFromRange(0, 2, 1),
+			Reason: unassignedSlotsReason("a", NewHashSlotsFromRange(0, 2, 1)),
 		},
 		AddSlotsOperation{
 			Target: riB,
-			Slots:  NewHashSlotsFromRange(8, 10, 1),
+			Slots:  NewHashSlotsFromRange(3, 4, 1),
+			Reason: unassignedSlotsReason("b", NewHashSlotsFromRange(3, 4, 1)),
 		},
 	}
 
@@ -593,3 +1261,313 @@ b 1:1@1 master,myself - 0 0 0 connected 0 6-7
 		t.Errorf("expected:\n%v\ngot:\n%v", expected, operations)
 	}
 }
+
+func TestDatabaseGetOperationsMigrationRepairFinish(t *testing.T) {
+	database := &Database{ManagedSlots: NewHashSlotsFromRange(0, 10, 1)}
+	database.RegisterGroup(MasterGroup{riA})
+	database.RegisterGroup(MasterGroup{riB})
+	database.Feed(riA, mustParseClusterNodes(`
+a 1:1@1 master,myself - 0 0 0 connected 0-5 [5->-b]
+b 1:1@1 master - 0 0 0 connected 6-10
+`))
+	database.Feed(riB, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected 0-5
+b 1:1@1 master,myself - 0 0 0 connected 6-10 [5-<-a]
+`))
+	operations := database.GetOperations()
+	expected := []Operation{
+		MigrateSlotOperation{
+			Source:        riA,
+			SourceID:      "a",
+			Destination:   riB,
+			DestinationID: "b",
+			Slot:          5,
+			Repair:        true,
+			Reason: Reason{
+				Code: ReasonOpenMigration,
+				Evidence: map[string]string{
+					"node":         "a",
+					"slot":         "5",
+					"migrating-to": "b",
+					"owner":        "a",
+				},
+			},
+		},
+	}
+
+	if !compareOperations(expected, operations) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, operations)
+	}
+}
+
+func TestDatabaseGetOperationsMigrationRepairRollback(t *testing.T) {
+	database := &Database{ManagedSlots: NewHashSlotsFromRange(0, 10, 1)}
+	database.RegisterGroup(MasterGroup{riA})
+	database.RegisterGroup(MasterGroup{riB})
+	database.Feed(riA, mustParseClusterNodes(`
+a 1:1@1 master,myself - 0 0 0 connected 0-5 [5->-x]
+b 1:1@1 master - 0 0 0 connected 6-10
+`))
+	database.Feed(riB, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected 0-5
+b 1:1@1 master,myself - 0 0 0 connected 6-10 [7-<-a]
+`))
+	operations := database.GetOperations()
+	expected := []Operation{
+		StabilizeSlotOperation{
+			Target: riA,
+			Slot:   5,
+			Reason: Reason{
+				Code: ReasonDanglingMigration,
+				Evidence: map[string]string{
+					"node":         "a",
+					"slot":         "5",
+					"migrating-to": "x",
+					"owner":        "a",
+				},
+			},
+		},
+		StabilizeSlotOperation{
+			Target: riB,
+			Slot:   7,
+			Reason: Reason{
+				Code: ReasonDanglingMigration,
+				Evidence: map[string]string{
+					"node":           "b",
+					"slot":           "7",
+					"importing-from": "a",
+					"owner":          "b",
+				},
+			},
+		},
+	}
+
+	if !compareOperations(expected, operations) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, operations)
+	}
+}
+
+func TestDatabaseMarkUnreachable(t *testing.T) {
+	database := &Database{}
+	database.RegisterGroup(MasterGroup{riA, riB})
+	errDown := errors.New("down")
+
+	if err := database.MarkUnreachable(riC, errDown); err == nil {
+		t.Error("expected an error")
+	}
+
+	database.Feed(riA, mustParseClusterNodes(`a 1:1@1 master,myself - 0 0 0 connected`))
+
+	if err := database.MarkUnreachable(riA, errDown); err == nil {
+		t.Error("expected an error")
+	}
+
+	if database.IsDegraded() {
+		t.Error("expected the database not to be degraded")
+	}
+
+	if err := database.MarkUnreachable(riB, errDown); err != nil {
+		t.Errorf("expected no error but got: %s", err)
+	}
+
+	if !database.IsDegraded() {
+		t.Error("expected the database to be degraded")
+	}
+
+	if errs := database.GetUnreachableErrors(); !reflect.DeepEqual(errs, []error{errDown}) {
+		t.Errorf("expected %v but got: %v", []error{errDown}, errs)
+	}
+
+	if err := database.Feed(riB, mustParseClusterNodes(`b 1:1@1 master,myself - 0 0 0 connected`)); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestDatabaseGetShortCircuited(t *testing.T) {
+	database := &Database{}
+	database.RegisterGroup(group)
+	database.MarkUnreachable(riA, errors.New("down"))
+	database.MarkUnreachable(riC, &CircuitOpenError{RedisInstance: riC})
+
+	expected := []RedisInstance{riC}
+
+	if redisInstances := database.GetShortCircuited(); !reflect.DeepEqual(redisInstances, expected) {
+		t.Errorf("expected %v but got: %v", expected, redisInstances)
+	}
+}
+
+func TestDatabaseGetOperationsDegradedMesh(t *testing.T) {
+	database := &Database{ManagedSlots: AllSlots}
+	database.RegisterGroup(group)
+	database.Feed(riA, mustParseClusterNodes(`
+a 1:1@1 master,myself - 0 0 0 connected
+x 1:1@1 master - 0 0 0 connected
+`))
+	database.Feed(riB, mustParseClusterNodes(`b 1:1@1 master,myself - 0 0 0 connected`))
+	database.MarkUnreachable(riC, errors.New("down"))
+	operations := database.GetOperations()
+	expected := []Operation{
+		MeetOperation{
+			Target: riA,
+			Other:  riB,
+			Reason: missingConnectionReason("a", "b", "group", group),
+		},
+		MeetOperation{
+			Target: riB,
+			Other:  riA,
+			Reason: missingConnectionReason("b", "a", "group", group),
+		},
+	}
+
+	if !compareOperations(expected, operations) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, operations)
+	}
+}
+
+func TestDatabaseGetOperationsDegradedReplication(t *testing.T) {
+	riD := RedisInstance{Hostname: "d"}
+	database := &Database{ManagedSlots: AllSlots}
+	database.RegisterGroup(MasterGroup{riA, riB})
+	database.RegisterGroup(MasterGroup{riC, riD})
+	database.Feed(riA, mustParseClusterNodes(`
+a 1:1@1 master,myself - 0 0 0 connected
+b 1:1@1 master - 0 0 0 connected
+c 1:1@1 master - 0 0 0 connected
+`))
+	database.Feed(riB, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected
+b 1:1@1 master,myself - 0 0 0 connected
+c 1:1@1 master - 0 0 0 connected
+`))
+	database.Feed(riC, mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected
+b 1:1@1 master - 0 0 0 connected
+c 1:1@1 master,myself - 0 0 0 connected
+d 1:1@1 master - 0 0 0 connected
+`))
+	database.MarkUnreachable(riD, errors.New("down"))
+	operations := database.GetOperations()
+	expected := []Operation{
+		ReplicateOperation{
+			Target:   riB,
+			Master:   riA,
+			MasterID: "a",
+			Reason:   multipleMastersReason(MasterGroup{riA, riB}, MasterGroup{riA, riB}),
+		},
+	}
+
+	if !compareOperations(expected, operations) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, operations)
+	}
+}
+
+func TestDatabaseGetOperationsDegradedAssignation(t *testing.T) {
+	database := &Database{ManagedSlots: AllSlots}
+	database.RegisterGroup(MasterGroup{riA})
+	database.RegisterGroup(MasterGroup{riB})
+	database.Feed(riA, mustParseClusterNodes(`a 1:1@1 master,myself - 0 0 0 connected`))
+	database.MarkUnreachable(riB, errors.New("down"))
+	operations := database.GetOperations()
+
+	if len(operations) != 0 {
+		t.Errorf("expected no operations but got:\n%v", operations)
+	}
+}
+
+// newFailedMasterDatabase returns a database in which "a" is unreachable, "b"
+// replicates it, and "c" flags it with the specified flags.
+func newFailedMasterDatabase(flags string, replicaFlags string) *Database {
+	database := &Database{ManagedSlots: AllSlots, AutomaticFailover: FailoverForce}
+	database.RegisterGroup(MasterGroup{riA, riB})
+	database.RegisterGroup(MasterGroup{riC})
+	database.MarkUnreachable(riA, errors.New("down"))
+	database.Feed(riB, mustParseClusterNodes(`
+a 1:1@1 master,`+flags+` - 0 0 0 disconnected 0-8191
+b 1:1@1 myself,`+replicaFlags+` a 0 0 0 connected
+c 1:1@1 master - 0 0 0 connected 8192-16383
+`))
+	database.Feed(riC, mustParseClusterNodes(`
+a 1:1@1 master,`+flags+` - 0 0 0 disconnected 0-8191
+b 1:1@1 `+replicaFlags+` a 0 0 0 connected
+c 1:1@1 master,myself - 0 0 0 connected 8192-16383
+`))
+
+	return database
+}
+
+func TestDatabaseGetOperationsFailover(t *testing.T) {
+	database := newFailedMasterDatabase("fail", "slave")
+	operations := database.GetOperations()
+	expected := []Operation{
+		FailoverOperation{
+			Target:   riB,
+			MasterID: "a",
+			Mode:     FailoverForce,
+			Reason: Reason{
+				Code: ReasonFailedMaster,
+				Evidence: map[string]string{
+					"master":             "a",
+					"fail-reports":       "1/1",
+					"replica":            "b",
+					"replication-offset": "0",
+				},
+			},
+		},
+	}
+
+	if !compareOperations(expected, operations) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, operations)
+	}
+}
+
+func TestDatabaseGetFailoverOperationsNone(t *testing.T) {
+	testCases := []struct {
+		Name         string
+		Flags        string
+		ReplicaFlags string
+	}{
+		{
+			Name:         "probable-fail",
+			Flags:        "fail?",
+			ReplicaFlags: "slave",
+		},
+		{
+			Name:         "no-failover",
+			Flags:        "fail",
+			ReplicaFlags: "slave,nofailover",
+		},
+		{
+			Name:         "failing-replica",
+			Flags:        "fail",
+			ReplicaFlags: "slave,fail?",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			database := newFailedMasterDatabase(testCase.Flags, testCase.ReplicaFlags)
+
+			if operations := database.GetFailoverOperations(); len(operations) != 0 {
+				t.Errorf("expected no operations but got: %v", operations)
+			}
+		})
+	}
+}
+
+func TestDatabaseGetFailoverOperationsDelay(t *testing.T) {
+	now := time.Now().UTC()
+	timeline := &Timeline{timeFunc: func() time.Time { return now }}
+
+	for i, expected := range []int{0, 0, 1} {
+		database := newFailedMasterDatabase("fail", "slave")
+		database.FailoverDelay = time.Second * 10
+		database.Timeline = timeline
+
+		if operations := database.GetFailoverOperations(); len(operations) != expected {
+			t.Errorf("expected %d operations at cycle %d but got: %v", expected, i, operations)
+		}
+
+		timeline.Commit()
+		now = now.Add(time.Second * 5)
+	}
+}
@@ -0,0 +1,260 @@
+package kredis
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Discoverer discovers the master groups to manage.
+//
+// Discover is called on every sync cycle, so that topology changes are picked
+// up without a restart.
+type Discoverer interface {
+	Discover(ctx context.Context) ([]MasterGroup, error)
+}
+
+// A StaticDiscoverer always discovers the same master groups.
+type StaticDiscoverer []MasterGroup
+
+// Discover returns the master groups.
+func (d StaticDiscoverer) Discover(ctx context.Context) ([]MasterGroup, error) {
+	return d, nil
+}
+
+// A FileDiscoverer discovers master groups from a file.
+//
+// The file is either a JSON array of master groups, each being a string or
+// an array of Redis instances, or a text file with one master group per
+// line. In the text format, empty lines and lines starting with '#' are
+// ignored, and each line may be prefixed with "- " and quoted. Other formats,
+// like YAML, are not supported.
+//
+// The file is checked on every call to Discover, and read again whenever its
+// modification time changes: changes are picked up within a sync period.
+type FileDiscoverer struct {
+	Path         string
+	lock         sync.Mutex
+	modTime      time.Time
+	masterGroups []MasterGroup
+}
+
+// Discover returns the master groups in the file.
+func (d *FileDiscoverer) Discover(ctx context.Context) (masterGroups []MasterGroup, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("discovering master groups from %s: %s", d.Path, err)
+		}
+	}()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	info, err := os.Stat(d.Path)
+
+	if err != nil {
+		return
+	}
+
+	if d.masterGroups != nil && info.ModTime().Equal(d.modTime) {
+		return d.masterGroups, nil
+	}
+
+	data, err := ioutil.ReadFile(d.Path)
+
+	if err != nil {
+		return
+	}
+
+	if masterGroups, err = ParseMasterGroups(data); err != nil {
+		return
+	}
+
+	d.modTime = info.ModTime()
+	d.masterGroups = masterGroups
+
+	return
+}
+
+// ParseMasterGroups parses master groups in the format of a FileDiscoverer.
+func ParseMasterGroups(data []byte) ([]MasterGroup, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		return parseJSONMasterGroups(trimmed)
+	}
+
+	masterGroups := []MasterGroup{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimSpace(strings.TrimPrefix(line, "- "))
+		line = strings.Trim(line, `"'`)
+
+		masterGroup, err := ParseMasterGroup(line)
+
+		if err != nil {
+			return nil, fmt.Errorf("parsing line %d: %s", i, err)
+		}
+
+		masterGroups = append(masterGroups, masterGroup)
+	}
+
+	return masterGroups, scanner.Err()
+}
+
+func parseJSONMasterGroups(data []byte) ([]MasterGroup, error) {
+	var items []json.RawMessage
+
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+
+	masterGroups := make([]MasterGroup, len(items))
+
+	for i, item := range items {
+		var s string
+		var instances []string
+
+		if err := json.Unmarshal(item, &s); err != nil {
+			if err = json.Unmarshal(item, &instances); err != nil {
+				return nil, fmt.Errorf("parsing master group %d: expected a string or an array of strings", i)
+			}
+
+			s = strings.Join(instances, ",")
+		}
+
+		masterGroup, err := ParseMasterGroup(s)
+
+		if err != nil {
+			return nil, fmt.Errorf("parsing master group %d: %s", i, err)
+		}
+
+		masterGroups[i] = masterGroup
+	}
+
+	return masterGroups, nil
+}
+
+// A DNSDiscoverer discovers master groups from the SRV records of a headless
+// service of a StatefulSet.
+//
+// Service, Proto and Name are passed to net.Resolver.LookupSRV. The targets
+// of the records are expected to be named after the pods, whose ordinals
+// determine the master groups: the first InstancesPerGroup ordinals belong
+// to the first master group, and so on.
+//
+// The service must publish the addresses of pods that are not ready
+// (`publishNotReadyAddresses`): otherwise, a restarting pod would disappear
+// from its master group and make its live master look removed.
+type DNSDiscoverer struct {
+	Service           string
+	Proto             string
+	Name              string
+	InstancesPerGroup int
+	Resolver          *net.Resolver
+}
+
+// Discover returns the master groups from the SRV records.
+func (d *DNSDiscoverer) Discover(ctx context.Context) (masterGroups []MasterGroup, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("discovering master groups from DNS: %s", err)
+		}
+	}()
+
+	resolver := d.Resolver
+
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	_, records, err := resolver.LookupSRV(ctx, d.Service, d.Proto, d.Name)
+
+	if err != nil {
+		return
+	}
+
+	instances := make([]ordinalInstance, len(records))
+
+	for i, record := range records {
+		hostname := strings.TrimSuffix(record.Target, ".")
+
+		if instances[i].Ordinal, err = parseOrdinal(strings.SplitN(hostname, ".", 2)[0]); err != nil {
+			return
+		}
+
+		instances[i].Instance = RedisInstance{
+			Hostname: hostname,
+			Port:     strconv.Itoa(int(record.Port)),
+		}
+	}
+
+	return groupByOrdinal(instances, d.InstancesPerGroup)
+}
+
+type ordinalInstance struct {
+	Ordinal  int
+	Instance RedisInstance
+}
+
+// parseOrdinal parses the ordinal of a StatefulSet pod from its name.
+func parseOrdinal(name string) (int, error) {
+	index := strings.LastIndex(name, "-")
+
+	if index < 0 {
+		return 0, fmt.Errorf("\"%s\" has no ordinal", name)
+	}
+
+	ordinal, err := strconv.Atoi(name[index+1:])
+
+	if err != nil || ordinal < 0 {
+		return 0, fmt.Errorf("\"%s\" has no ordinal", name)
+	}
+
+	return ordinal, nil
+}
+
+// groupByOrdinal groups instances in master groups of the specified size,
+// according to their ordinals.
+//
+// Master groups whose instances are all missing are skipped.
+func groupByOrdinal(instances []ordinalInstance, instancesPerGroup int) ([]MasterGroup, error) {
+	if instancesPerGroup <= 0 {
+		return nil, fmt.Errorf("invalid number of instances per group: %d", instancesPerGroup)
+	}
+
+	instances = append([]ordinalInstance(nil), instances...)
+
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Ordinal < instances[j].Ordinal })
+
+	masterGroups := []MasterGroup{}
+
+	for i, instance := range instances {
+		if i > 0 && instances[i-1].Ordinal == instance.Ordinal {
+			return nil, fmt.Errorf("duplicate ordinal %d: %s and %s", instance.Ordinal, instances[i-1].Instance, instance.Instance)
+		}
+
+		if i == 0 || instances[i-1].Ordinal/instancesPerGroup != instance.Ordinal/instancesPerGroup {
+			masterGroups = append(masterGroups, MasterGroup{})
+		}
+
+		masterGroups[len(masterGroups)-1] = append(masterGroups[len(masterGroups)-1], instance.Instance)
+	}
+
+	return masterGroups, nil
+}
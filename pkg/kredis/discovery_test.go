@@ -0,0 +1,197 @@
+package kredis
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseMasterGroups(t *testing.T) {
+	expected := []MasterGroup{
+		{{"a", "6379"}, {"b", "6380"}},
+		{{"c", "6379"}},
+	}
+
+	testCases := []struct {
+		Name string
+		Data string
+	}{
+		{
+			Name: "text",
+			Data: "# groups\na,b:6380\n\nc\n",
+		},
+		{
+			Name: "yaml",
+			Data: "- a,b:6380\n- \"c\"\n",
+		},
+		{
+			Name: "json-strings",
+			Data: `["a,b:6380", "c"]`,
+		},
+		{
+			Name: "json-arrays",
+			Data: `[["a", "b:6380"], ["c"]]`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			masterGroups, err := ParseMasterGroups([]byte(testCase.Data))
+
+			if err != nil {
+				t.Fatalf("expected no error but got: %s", err)
+			}
+
+			if !reflect.DeepEqual(masterGroups, expected) {
+				t.Errorf("expected:\n%v\ngot:\n%v", expected, masterGroups)
+			}
+		})
+	}
+}
+
+func TestParseMasterGroupsFailure(t *testing.T) {
+	testCases := []string{
+		"a:1:2",
+		`[1, 2]`,
+		`["a", `,
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase, func(t *testing.T) {
+			_, err := ParseMasterGroups([]byte(testCase))
+
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestFileDiscoverer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kredis")
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	discoverer := &FileDiscoverer{Path: filepath.Join(dir, "master-groups")}
+	ctx := context.Background()
+
+	if _, err = discoverer.Discover(ctx); err == nil {
+		t.Error("expected an error")
+	}
+
+	if err = ioutil.WriteFile(discoverer.Path, []byte("a,b\n"), 0644); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	masterGroups, err := discoverer.Discover(ctx)
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	expected := []MasterGroup{{{"a", "6379"}, {"b", "6379"}}}
+
+	if !reflect.DeepEqual(masterGroups, expected) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, masterGroups)
+	}
+
+	if err = ioutil.WriteFile(discoverer.Path, []byte("a,b\nc,d\n"), 0644); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	// Make sure the modification time changes, whatever the resolution of the
+	// file system.
+	modTime := time.Now().Add(time.Second)
+
+	if err = os.Chtimes(discoverer.Path, modTime, modTime); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	if masterGroups, err = discoverer.Discover(ctx); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	expected = append(expected, MasterGroup{{"c", "6379"}, {"d", "6379"}})
+
+	if !reflect.DeepEqual(masterGroups, expected) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, masterGroups)
+	}
+}
+
+func TestParseOrdinal(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Expected int
+		Error    bool
+	}{
+		{Name: "redis-0", Expected: 0},
+		{Name: "my-redis-12", Expected: 12},
+		{Name: "redis", Error: true},
+		{Name: "redis-a", Error: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			ordinal, err := parseOrdinal(testCase.Name)
+
+			if testCase.Error {
+				if err == nil {
+					t.Error("expected an error")
+				}
+			} else if err != nil {
+				t.Errorf("expected no error but got: %s", err)
+			} else if ordinal != testCase.Expected {
+				t.Errorf("expected: %d, got: %d", testCase.Expected, ordinal)
+			}
+		})
+	}
+}
+
+func TestGroupByOrdinal(t *testing.T) {
+	instances := []ordinalInstance{
+		{Ordinal: 4, Instance: RedisInstance{"e", "6379"}},
+		{Ordinal: 0, Instance: RedisInstance{"a", "6379"}},
+		{Ordinal: 1, Instance: RedisInstance{"b", "6379"}},
+		{Ordinal: 7, Instance: RedisInstance{"h", "6379"}},
+		{Ordinal: 2, Instance: RedisInstance{"c", "6379"}},
+	}
+
+	masterGroups, err := groupByOrdinal(instances, 3)
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	expected := []MasterGroup{
+		{{"a", "6379"}, {"b", "6379"}, {"c", "6379"}},
+		{{"e", "6379"}},
+		{{"h", "6379"}},
+	}
+
+	if !reflect.DeepEqual(masterGroups, expected) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, masterGroups)
+	}
+}
+
+func TestGroupByOrdinalFailure(t *testing.T) {
+	instances := []ordinalInstance{
+		{Ordinal: 0, Instance: RedisInstance{"a", "6379"}},
+		{Ordinal: 0, Instance: RedisInstance{"b", "6379"}},
+	}
+
+	if _, err := groupByOrdinal(instances, 3); err == nil {
+		t.Error("expected an error")
+	}
+
+	if _, err := groupByOrdinal(instances[:1], 0); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -69,6 +69,15 @@ func (f *ErrorFeed) PopErrors() []ErrorItem {
 	return nil
 }
 
+// Count returns the number of errors currently aggregated in the feed.
+func (f *ErrorFeed) Count() (count int) {
+	for _, item := range f.errors {
+		count += item.Count
+	}
+
+	return
+}
+
 // Reset the list of errors.
 func (f *ErrorFeed) Reset() {
 	f.firstErrorTime = timeZero
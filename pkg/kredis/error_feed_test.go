@@ -52,3 +52,27 @@ func TestErrorFeed(t *testing.T) {
 	}
 
 }
+
+func TestErrorFeedCount(t *testing.T) {
+	feed := ErrorFeed{}
+	errA := errors.New("a")
+	errB := errors.New("b")
+
+	if count := feed.Count(); count != 0 {
+		t.Errorf("expected a count of 0 but got: %d", count)
+	}
+
+	feed.Add(errA)
+	feed.Add(errB)
+	feed.Add(errA)
+
+	if count := feed.Count(); count != 3 {
+		t.Errorf("expected a count of 3 but got: %d", count)
+	}
+
+	feed.Reset()
+
+	if count := feed.Count(); count != 0 {
+		t.Errorf("expected a count of 0 after a reset but got: %d", count)
+	}
+}
@@ -0,0 +1,293 @@
+package kredis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// FailoverMode represents the way a replica takes over its master.
+//
+// An empty FailoverMode is a coordinated failover, that requires the master
+// to be reachable.
+type FailoverMode string
+
+const (
+	// FailoverForce takes over without the agreement of the master, but
+	// still requires an election among the masters.
+	FailoverForce FailoverMode = "force"
+	// FailoverTakeover takes over without any agreement, by bumping the
+	// configuration epoch.
+	FailoverTakeover FailoverMode = "takeover"
+)
+
+// ParseFailoverPolicy parses an automatic failover policy: either "none", which
+// disables automatic failovers, or one of the forced failover modes.
+func ParseFailoverPolicy(s string) (FailoverMode, error) {
+	switch s {
+	case "none":
+		return "", nil
+	case string(FailoverForce), string(FailoverTakeover):
+		return FailoverMode(s), nil
+	default:
+		return "", fmt.Errorf("unknown failover policy \"%s\"", s)
+	}
+}
+
+// switchoverPollPeriod is the period at which Switchover polls the members of
+// the master group.
+var switchoverPollPeriod = time.Millisecond * 100
+
+// ParseInfo parses the reply of the `INFO` Redis command into its fields.
+//
+// Section headers and empty lines are ignored.
+func ParseInfo(s string) map[string]string {
+	fields := map[string]string{}
+
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if i := strings.Index(line, ":"); i > 0 {
+			fields[line[:i]] = line[i+1:]
+		}
+	}
+
+	return fields
+}
+
+// GetReplicationOffset gets the replication offset of the specified
+// redisInstance: the offset it produced if it is a master, or the offset it
+// processed if it is a replica.
+func (m *Manager) GetReplicationOffset(ctx context.Context, redisInstance RedisInstance) (offset int64, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("fetching replication offset for %s: %s", redisInstance, err)
+		}
+	}()
+
+	conn := m.getConn(ctx, redisInstance)
+	defer conn.Close()
+
+	var data string
+	data, err = redis.String(conn.Do("INFO", "replication"))
+
+	if err != nil {
+		return
+	}
+
+	info := ParseInfo(data)
+	field := "slave_repl_offset"
+
+	if info["role"] == "master" {
+		field = "master_repl_offset"
+	}
+
+	value, ok := info[field]
+
+	if !ok {
+		err = fmt.Errorf("no %s field", field)
+		return
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Switchover performs a planned failover of the specified master group: a
+// replica of its master is promoted once it caught up with it, and the new
+// roles are confirmed by every member of the master group.
+//
+// If replica is the zero value, the replica with the highest replication
+// offset is picked. The replication offsets, and then the roles, each have
+// the specified timeout to converge: a slow catch-up doesn't shorten the time
+// left to confirm the roles.
+func (m *Manager) Switchover(ctx context.Context, masterGroup MasterGroup, replica RedisInstance, timeout time.Duration) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("switching over %s: %s", masterGroup, err)
+		}
+	}()
+
+	catchUpCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	selves := make(map[RedisInstance]ClusterNode, len(masterGroup))
+	var master RedisInstance
+	masters := 0
+
+	for _, redisInstance := range masterGroup {
+		var nodes ClusterNodes
+
+		if nodes, err = m.GetClusterNodes(catchUpCtx, redisInstance); err != nil {
+			return
+		}
+
+		if selves[redisInstance], err = nodes.Self(); err != nil {
+			return
+		}
+
+		if selves[redisInstance].Flags[FlagMaster] {
+			master = redisInstance
+			masters++
+		}
+	}
+
+	if masters != 1 {
+		return fmt.Errorf("expected one master but found %d", masters)
+	}
+
+	masterID := selves[master].ID
+	var replicas []RedisInstance
+
+	for _, redisInstance := range masterGroup {
+		if node := selves[redisInstance]; node.Flags[FlagSlave] && node.MasterID == masterID {
+			replicas = append(replicas, redisInstance)
+		}
+	}
+
+	if replica == (RedisInstance{}) {
+		if replica, err = m.pickSwitchoverReplica(catchUpCtx, replicas); err != nil {
+			return
+		}
+	} else if !inRedisInstances(replica, replicas) {
+		return fmt.Errorf("%s is not a replica of the master %s", replica, master)
+	}
+
+	m.Logger.Log("event", "switchover", "master", master, "replica", replica)
+
+	var masterOffset, replicaOffset int64
+
+	readOffsets := func() (err error) {
+		var offset int64
+
+		if offset, err = m.GetReplicationOffset(catchUpCtx, master); err != nil {
+			return
+		}
+
+		masterOffset = offset
+
+		if offset, err = m.GetReplicationOffset(catchUpCtx, replica); err != nil {
+			return
+		}
+
+		replicaOffset = offset
+
+		return
+	}
+
+	for {
+		if err = readOffsets(); err == nil && replicaOffset >= masterOffset {
+			break
+		}
+
+		// A command interrupted by the deadline means that the offsets
+		// didn't converge in time.
+		if err != nil && catchUpCtx.Err() == nil {
+			return
+		}
+
+		select {
+		case <-catchUpCtx.Done():
+			return fmt.Errorf("replication offsets did not converge within %s: master at %d, replica at %d", timeout, masterOffset, replicaOffset)
+		case <-time.After(switchoverPollPeriod):
+		}
+	}
+
+	confirmCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err = m.ClusterFailover(confirmCtx, replica, ""); err != nil {
+		return
+	}
+
+	replicaID := selves[replica].ID
+
+	for {
+		var lastErr error
+
+		for _, redisInstance := range masterGroup {
+			if lastErr = m.checkSwitchover(confirmCtx, redisInstance, masterID, replicaID); lastErr != nil {
+				break
+			}
+		}
+
+		if lastErr == nil {
+			return nil
+		}
+
+		select {
+		case <-confirmCtx.Done():
+			return fmt.Errorf("roles did not converge within %s: %s", timeout, lastErr)
+		case <-time.After(switchoverPollPeriod):
+		}
+	}
+}
+
+// pickSwitchoverReplica returns the replica with the highest replication
+// offset.
+func (m *Manager) pickSwitchoverReplica(ctx context.Context, replicas []RedisInstance) (replica RedisInstance, err error) {
+	if len(replicas) == 0 {
+		err = errors.New("the master has no replica in the master group")
+		return
+	}
+
+	best := int64(-1)
+
+	for _, redisInstance := range replicas {
+		var offset int64
+
+		if offset, err = m.GetReplicationOffset(ctx, redisInstance); err != nil {
+			return
+		}
+
+		if offset > best {
+			replica = redisInstance
+			best = offset
+		}
+	}
+
+	return
+}
+
+// checkSwitchover checks that the specified redisInstance sees the replica as
+// a master and the former master as its replica.
+func (m *Manager) checkSwitchover(ctx context.Context, redisInstance RedisInstance, masterID ClusterNodeID, replicaID ClusterNodeID) error {
+	nodes, err := m.GetClusterNodes(ctx, redisInstance)
+
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		switch node.ID {
+		case replicaID:
+			if !node.Flags[FlagMaster] {
+				return fmt.Errorf("%s still sees %s as a replica", redisInstance, replicaID)
+			}
+		case masterID:
+			if !node.Flags[FlagSlave] || node.MasterID != replicaID {
+				return fmt.Errorf("%s doesn't see %s as a replica of %s yet", redisInstance, masterID, replicaID)
+			}
+		}
+	}
+
+	return nil
+}
+
+func inRedisInstances(redisInstance RedisInstance, redisInstances []RedisInstance) bool {
+	for _, other := range redisInstances {
+		if other == redisInstance {
+			return true
+		}
+	}
+
+	return false
+}
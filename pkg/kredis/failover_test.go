@@ -0,0 +1,333 @@
+package kredis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+func TestParseFailoverPolicy(t *testing.T) {
+	testCases := []struct {
+		Policy   string
+		Expected FailoverMode
+	}{
+		{Policy: "none", Expected: ""},
+		{Policy: "force", Expected: FailoverForce},
+		{Policy: "takeover", Expected: FailoverTakeover},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Policy, func(t *testing.T) {
+			mode, err := ParseFailoverPolicy(testCase.Policy)
+
+			if err != nil {
+				t.Fatalf("expected no error but got: %s", err)
+			}
+
+			if mode != testCase.Expected {
+				t.Errorf("expected: %s, got: %s", testCase.Expected, mode)
+			}
+		})
+	}
+
+	if _, err := ParseFailoverPolicy("always"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	info := ParseInfo("# Replication\r\nrole:slave\r\nmaster_host:10.0.0.1\r\nslave_repl_offset:42\r\n\r\n# CPU\r\nused_cpu_sys:0.5\r\n")
+	expected := map[string]string{
+		"role":              "slave",
+		"master_host":       "10.0.0.1",
+		"slave_repl_offset": "42",
+		"used_cpu_sys":      "0.5",
+	}
+
+	if !reflect.DeepEqual(info, expected) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, info)
+	}
+}
+
+func TestManagerGetReplicationOffset(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Info     string
+		Expected int64
+	}{
+		{
+			Name:     "master",
+			Info:     "# Replication\r\nrole:master\r\nmaster_repl_offset:1024\r\n",
+			Expected: 1024,
+		},
+		{
+			Name:     "replica",
+			Info:     "# Replication\r\nrole:slave\r\nslave_repl_offset:1000\r\n",
+			Expected: 1000,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			redisInstance, commands := serveFakeRedis(t, fmt.Sprintf("$%d\r\n%s", len(testCase.Info), testCase.Info))
+			manager := &Manager{Pool: &Pool{}}
+			defer manager.Pool.Close()
+
+			offset, err := manager.GetReplicationOffset(context.Background(), redisInstance)
+
+			if err != nil {
+				t.Fatalf("expected no error but got: %s", err)
+			}
+
+			if offset != testCase.Expected {
+				t.Errorf("expected: %d, got: %d", testCase.Expected, offset)
+			}
+
+			if command := <-commands; !reflect.DeepEqual(command, []string{"INFO", "replication"}) {
+				t.Errorf("expected: %v, got: %v", []string{"INFO", "replication"}, command)
+			}
+		})
+	}
+}
+
+// serveFakeClusterNodes serves the specified `CLUSTER NODES` reply for a
+// single connection.
+func serveFakeClusterNodes(t *testing.T, nodes string) RedisInstance {
+	redisInstance, _ := serveFakeRedis(t, fmt.Sprintf("$%d\r\n%s", len(nodes), nodes))
+
+	return redisInstance
+}
+
+func TestManagerBuildDatabaseFailedMaster(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	// The failed master refuses connections.
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	master := RedisInstance{Hostname: host, Port: port}
+	listener.Close()
+
+	replica := serveFakeClusterNodes(t, ""+
+		"a 10.0.0.1:6379@16379 master,fail - 0 0 1 disconnected 0-8191\n"+
+		"b 10.0.0.2:6379@16379 myself,slave a 0 0 1 connected\n"+
+		"c 10.0.0.3:6379@16379 master - 0 0 2 connected 8192-16383\n")
+	other := serveFakeClusterNodes(t, ""+
+		"a 10.0.0.1:6379@16379 master,fail - 0 0 1 disconnected 0-8191\n"+
+		"b 10.0.0.2:6379@16379 slave a 0 0 1 connected\n"+
+		"c 10.0.0.3:6379@16379 myself,master - 0 0 2 connected 8192-16383\n")
+
+	manager := &Manager{
+		Pool:              &Pool{},
+		AutomaticFailover: FailoverForce,
+		AllowUnreachable:  true,
+	}
+	defer manager.Pool.Close()
+
+	db, err := manager.BuildDatabase(context.Background(), []MasterGroup{{master, replica}, {other}})
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	operations := db.GetOperations()
+	expected := []Operation{
+		FailoverOperation{
+			Target:   replica,
+			MasterID: "a",
+			Mode:     FailoverForce,
+			Reason: Reason{
+				Code: ReasonFailedMaster,
+				Evidence: map[string]string{
+					"master":             "a",
+					"fail-reports":       "1/1",
+					"replica":            "b",
+					"replication-offset": "0",
+				},
+			},
+		},
+	}
+
+	if !compareOperations(expected, operations) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, operations)
+	}
+}
+
+// fakeSwitchoverCluster simulates a master group made of the master "m" and
+// its replicas "r1" and "r2".
+type fakeSwitchoverCluster struct {
+	lock sync.Mutex
+	// offsets are the replication offsets, by node ID.
+	offsets map[string]int64
+	// catchUp is added to the offset of a replica every time it is read,
+	// until it reaches the offset of the master.
+	catchUp int64
+	// converge indicates whether the roles change after a failover.
+	converge bool
+	// promoted is the ID of the replica that was asked to fail over.
+	promoted       string
+	redisInstances map[string]RedisInstance
+	stops          []func()
+}
+
+func newFakeSwitchoverCluster(t *testing.T, offsets map[string]int64, catchUp int64, converge bool) *fakeSwitchoverCluster {
+	c := &fakeSwitchoverCluster{
+		offsets:        offsets,
+		catchUp:        catchUp,
+		converge:       converge,
+		redisInstances: map[string]RedisInstance{},
+	}
+
+	for _, id := range []string{"m", "r1", "r2"} {
+		id := id
+		redisInstance, stop := serveScriptedRedis(t, func(command []string) string { return c.handle(id, command) })
+		c.redisInstances[id] = redisInstance
+		c.stops = append(c.stops, stop)
+	}
+
+	return c
+}
+
+func (c *fakeSwitchoverCluster) masterGroup() MasterGroup {
+	return MasterGroup{c.redisInstances["m"], c.redisInstances["r1"], c.redisInstances["r2"]}
+}
+
+func (c *fakeSwitchoverCluster) stop() {
+	for _, stop := range c.stops {
+		stop()
+	}
+}
+
+func (c *fakeSwitchoverCluster) handle(id string, command []string) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	master := "m"
+
+	if c.promoted != "" && c.converge {
+		master = c.promoted
+	}
+
+	switch strings.Join(command, " ") {
+	case "CLUSTER NODES":
+		var lines []string
+
+		for i, nodeID := range []string{"m", "r1", "r2"} {
+			flags, masterID := "master", "-"
+
+			if nodeID != master {
+				flags, masterID = "slave", master
+			}
+
+			if nodeID == id {
+				flags = "myself," + flags
+			}
+
+			lines = append(lines, fmt.Sprintf("%s 10.0.0.%d:6379@16379 %s %s 0 0 1 connected", nodeID, i, flags, masterID))
+		}
+
+		return bulkString(strings.Join(lines, "\n") + "\n")
+	case "INFO replication":
+		if id == master {
+			return bulkString(fmt.Sprintf("role:master\r\nmaster_repl_offset:%d\r\n", c.offsets[id]))
+		}
+
+		if c.offsets[id] < c.offsets[master] {
+			c.offsets[id] += c.catchUp
+		}
+
+		return bulkString(fmt.Sprintf("role:slave\r\nslave_repl_offset:%d\r\n", c.offsets[id]))
+	case "CLUSTER FAILOVER":
+		c.promoted = id
+
+		return "+OK"
+	default:
+		return "-ERR unknown command"
+	}
+}
+
+func TestManagerSwitchover(t *testing.T) {
+	defer func(period time.Duration) { switchoverPollPeriod = period }(switchoverPollPeriod)
+	switchoverPollPeriod = time.Millisecond
+
+	// "r2" is the most up-to-date replica and needs a few polls to catch up.
+	cluster := newFakeSwitchoverCluster(t, map[string]int64{"m": 100, "r1": 40, "r2": 70}, 10, true)
+	defer cluster.stop()
+
+	manager := &Manager{Pool: &Pool{}, Logger: log.NewNopLogger()}
+	defer manager.Pool.Close()
+
+	if err := manager.Switchover(context.Background(), cluster.masterGroup(), RedisInstance{}, time.Second); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	cluster.lock.Lock()
+	defer cluster.lock.Unlock()
+
+	if cluster.promoted != "r2" {
+		t.Errorf("expected r2 to be promoted but got: %s", cluster.promoted)
+	}
+
+	if cluster.offsets["r2"] < cluster.offsets["m"] {
+		t.Errorf("expected r2 to catch up before being promoted but it is at %d", cluster.offsets["r2"])
+	}
+}
+
+func TestManagerSwitchoverFailure(t *testing.T) {
+	defer func(period time.Duration) { switchoverPollPeriod = period }(switchoverPollPeriod)
+	switchoverPollPeriod = time.Millisecond
+
+	testCases := []struct {
+		Name     string
+		CatchUp  int64
+		Converge bool
+		Promoted string
+		Error    string
+	}{
+		{
+			Name:     "offsets",
+			CatchUp:  0,
+			Converge: true,
+			Error:    "replication offsets did not converge within",
+		},
+		{
+			Name:     "roles",
+			CatchUp:  10,
+			Converge: false,
+			Promoted: "r2",
+			Error:    "roles did not converge within",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			cluster := newFakeSwitchoverCluster(t, map[string]int64{"m": 100, "r1": 40, "r2": 70}, testCase.CatchUp, testCase.Converge)
+			defer cluster.stop()
+
+			manager := &Manager{Pool: &Pool{}, Logger: log.NewNopLogger()}
+			defer manager.Pool.Close()
+
+			err := manager.Switchover(context.Background(), cluster.masterGroup(), RedisInstance{}, time.Millisecond*50)
+
+			if err == nil || !strings.Contains(err.Error(), testCase.Error) {
+				t.Fatalf("expected an error containing \"%s\" but got: %v", testCase.Error, err)
+			}
+
+			cluster.lock.Lock()
+			defer cluster.lock.Unlock()
+
+			if cluster.promoted != testCase.Promoted {
+				t.Errorf("expected %q to be promoted but got: %q", testCase.Promoted, cluster.promoted)
+			}
+		})
+	}
+}
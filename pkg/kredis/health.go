@@ -0,0 +1,52 @@
+package kredis
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+func writeProbeResult(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, err)
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+}
+
+// HealthzHandler returns an HTTP handler that succeeds as long as the manager
+// loop ticked within the specified threshold.
+func HealthzHandler(manager *Manager, threshold time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeProbeResult(w, manager.Status().Alive(time.Now().UTC(), threshold))
+	})
+}
+
+// ReadyzHandler returns an HTTP handler that succeeds when the last database
+// build succeeded and the manager is in the stable state.
+func ReadyzHandler(manager *Manager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeProbeResult(w, manager.Status().Ready())
+	})
+}
+
+// StandbyHandler returns an HTTP handler that succeeds while the specified
+// elector is not the leader, and delegates to handler otherwise.
+//
+// Standby instances don't run a manager and are healthy as long as they
+// campaign for leadership.
+func StandbyHandler(elector *LeaderElector, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !elector.IsLeader() {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			fmt.Fprintln(w, "standby")
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
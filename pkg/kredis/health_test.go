@@ -0,0 +1,160 @@
+package kredis
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+func TestManagerStatusAlive(t *testing.T) {
+	now := time.Now().UTC()
+	status := ManagerStatus{}
+
+	if err := status.Alive(now, time.Second); err == nil {
+		t.Error("expected an error")
+	}
+
+	status.LastTick = now.Add(-time.Second)
+
+	if err := status.Alive(now, time.Second); err != nil {
+		t.Errorf("expected no error but got: %s", err)
+	}
+
+	status.LastTick = now.Add(-time.Second * 2)
+
+	if err := status.Alive(now, time.Second); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestManagerStatusReady(t *testing.T) {
+	status := ManagerStatus{
+		State: ManagerStateStable,
+	}
+
+	if err := status.Ready(); err == nil {
+		t.Error("expected an error")
+	}
+
+	status.Synced = true
+
+	if err := status.Ready(); err != nil {
+		t.Errorf("expected no error but got: %s", err)
+	}
+
+	status.LastBuildError = errors.New("fail")
+
+	if err := status.Ready(); err == nil {
+		t.Error("expected an error")
+	}
+
+	status.LastBuildError = nil
+	status.Degraded = true
+
+	if err := status.Ready(); err == nil {
+		t.Error("expected an error")
+	}
+
+	status.Degraded = false
+	status.State = ManagerStateMesh
+
+	if err := status.Ready(); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestHealthHandlers(t *testing.T) {
+	manager := &Manager{}
+	manager.status = ManagerStatus{
+		State:    ManagerStateMesh,
+		LastTick: time.Now().UTC(),
+		Synced:   true,
+	}
+
+	recorder := httptest.NewRecorder()
+	HealthzHandler(manager, time.Minute).ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected %d but got %d", http.StatusOK, recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	ReadyzHandler(manager).ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected %d but got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+
+	manager.status.State = ManagerStateStable
+	recorder = httptest.NewRecorder()
+	ReadyzHandler(manager).ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected %d but got %d", http.StatusOK, recorder.Code)
+	}
+}
+
+// slowOperation takes some time to execute and records whether the manager
+// was alive when it started.
+type slowOperation struct {
+	Manager   *Manager
+	Duration  time.Duration
+	Threshold time.Duration
+	Errors    *[]error
+}
+
+func (o slowOperation) Name() string            { return "slow" }
+func (o slowOperation) Describe() []interface{} { return nil }
+func (o slowOperation) State() ManagerState     { return ManagerStateAssignation }
+func (o slowOperation) Explain() Reason         { return Reason{} }
+
+func (o slowOperation) Execute(ctx context.Context, executor Executor) error {
+	*o.Errors = append(*o.Errors, o.Manager.Status().Alive(time.Now().UTC(), o.Threshold))
+	time.Sleep(o.Duration)
+
+	return nil
+}
+
+func TestManagerExecuteLiveness(t *testing.T) {
+	threshold := time.Millisecond * 100
+	manager := &Manager{Logger: log.NewNopLogger()}
+	manager.status = ManagerStatus{LastTick: time.Now().UTC()}
+
+	var aliveErrors []error
+	var operations []Operation
+
+	// The whole cycle lasts three times longer than the threshold.
+	for i := 0; i < 10; i++ {
+		operations = append(operations, slowOperation{
+			Manager:   manager,
+			Duration:  threshold * 3 / 10,
+			Threshold: threshold,
+			Errors:    &aliveErrors,
+		})
+	}
+
+	start := time.Now()
+
+	if err := manager.execute(context.Background(), operations, func(error) {}); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	if elapsed := time.Since(start); elapsed <= threshold {
+		t.Fatalf("expected the cycle to last longer than %s but it lasted %s", threshold, elapsed)
+	}
+
+	for i, err := range aliveErrors {
+		if err != nil {
+			t.Errorf("expected the manager to be alive before operation %d but got: %s", i, err)
+		}
+	}
+
+	if err := manager.Status().Alive(time.Now().UTC(), threshold); err != nil {
+		t.Errorf("expected no error but got: %s", err)
+	}
+}
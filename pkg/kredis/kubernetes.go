@@ -0,0 +1,175 @@
+package kredis
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	kubernetesServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	kubernetesTokenFile         = kubernetesServiceAccountDir + "/token"
+	kubernetesCAFile            = kubernetesServiceAccountDir + "/ca.crt"
+	kubernetesNamespaceFile     = kubernetesServiceAccountDir + "/namespace"
+)
+
+// A KubernetesClient is a minimal client for the Kubernetes API.
+type KubernetesClient struct {
+	// BaseURL is the URL of the Kubernetes API server.
+	BaseURL string
+	// TokenFile is the path to a file containing the bearer token to use. It
+	// is read before every request so that rotated tokens are picked up.
+	TokenFile string
+	// HTTPClient is the HTTP client to use. If nil, http.DefaultClient is
+	// used.
+	HTTPClient *http.Client
+}
+
+// A KubernetesError is returned when the Kubernetes API answers with an
+// unexpected status code.
+type KubernetesError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+}
+
+func (e *KubernetesError) Error() string {
+	return fmt.Sprintf("%s %s: kubernetes API error (%d): %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// IsKubernetesStatus checks whether the specified error is a KubernetesError
+// with the specified status code.
+func IsKubernetesStatus(err error, statusCode int) bool {
+	if err, ok := err.(*KubernetesError); ok {
+		return err.StatusCode == statusCode
+	}
+
+	return false
+}
+
+// NewInClusterKubernetesClient creates a Kubernetes client that uses the
+// service account of the pod it runs in.
+func NewInClusterKubernetesClient() (*KubernetesClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+
+	if host == "" || port == "" {
+		return nil, errors.New("not running inside a Kubernetes cluster")
+	}
+
+	ca, err := ioutil.ReadFile(kubernetesCAFile)
+
+	if err != nil {
+		return nil, fmt.Errorf("reading Kubernetes CA: %s", err)
+	}
+
+	pool := x509.NewCertPool()
+
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificate found in %s", kubernetesCAFile)
+	}
+
+	return &KubernetesClient{
+		BaseURL:   "https://" + net.JoinHostPort(host, port),
+		TokenFile: kubernetesTokenFile,
+		HTTPClient: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		},
+	}, nil
+}
+
+// InClusterNamespace returns the namespace of the pod kredis runs in.
+func InClusterNamespace() (string, error) {
+	data, err := ioutil.ReadFile(kubernetesNamespaceFile)
+
+	if err != nil {
+		return "", fmt.Errorf("reading Kubernetes namespace: %s", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Do performs a request against the Kubernetes API.
+//
+// If in is not nil, it is sent as the JSON body of the request. If out is
+// not nil, the JSON body of the response is decoded into it.
+func (c *KubernetesClient) Do(ctx context.Context, method, path string, in, out interface{}) (err error) {
+	defer func() {
+		if _, ok := err.(*KubernetesError); err != nil && !ok {
+			err = fmt.Errorf("%s %s: %s", method, path, err)
+		}
+	}()
+
+	var body bytes.Buffer
+
+	if in != nil {
+		if err = json.NewEncoder(&body).Encode(in); err != nil {
+			return
+		}
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.BaseURL, "/")+path, &body)
+
+	if err != nil {
+		return
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.TokenFile != "" {
+		var token []byte
+
+		if token, err = ioutil.ReadFile(c.TokenFile); err != nil {
+			return
+		}
+
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	httpClient := c.HTTPClient
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+
+	if err != nil {
+		return
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := ioutil.ReadAll(resp.Body)
+
+		return &KubernetesError{
+			Method:     method,
+			Path:       path,
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(data)),
+		}
+	}
+
+	if out != nil {
+		err = json.NewDecoder(resp.Body).Decode(out)
+	}
+
+	return
+}
@@ -0,0 +1,166 @@
+package kredis
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// kubernetesMicroTimeFormat is the format of the Kubernetes MicroTime type.
+const kubernetesMicroTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+type kubernetesLeaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+}
+
+type kubernetesObjectMeta struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type kubernetesLease struct {
+	APIVersion string               `json:"apiVersion"`
+	Kind       string               `json:"kind"`
+	Metadata   kubernetesObjectMeta `json:"metadata"`
+	Spec       kubernetesLeaseSpec  `json:"spec"`
+}
+
+// A KubernetesLeaseLock is a Lock backed by a Kubernetes Lease object.
+//
+// The clocks of the candidates may differ, so the lease times written by
+// another holder are never compared with the local clock: a lease held by
+// someone else expires once it wasn't renewed for its duration since it was
+// last seen renewed, as measured locally.
+type KubernetesLeaseLock struct {
+	Client       *KubernetesClient
+	Namespace    string
+	Name         string
+	timeFunc     func() time.Time
+	observedSpec kubernetesLeaseSpec
+	observedTime time.Time
+}
+
+func (l *KubernetesLeaseLock) init() {
+	if l.timeFunc == nil {
+		l.timeFunc = func() time.Time { return time.Now().UTC() }
+	}
+}
+
+func (l *KubernetesLeaseLock) path(named bool) string {
+	path := fmt.Sprintf("/apis/coordination.k8s.io/v1/namespaces/%s/leases", l.Namespace)
+
+	if named {
+		path += "/" + l.Name
+	}
+
+	return path
+}
+
+// Acquire tries to acquire, or renew, the lease.
+func (l *KubernetesLeaseLock) Acquire(ctx context.Context, holder string, duration time.Duration) (acquired bool, err error) {
+	l.init()
+
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("acquiring lease %s/%s: %s", l.Namespace, l.Name, err)
+		}
+	}()
+
+	now := l.timeFunc()
+	lease := &kubernetesLease{}
+
+	if err = l.Client.Do(ctx, http.MethodGet, l.path(true), nil, lease); err != nil {
+		if !IsKubernetesStatus(err, http.StatusNotFound) {
+			return
+		}
+
+		lease = &kubernetesLease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata: kubernetesObjectMeta{
+				Name:      l.Name,
+				Namespace: l.Namespace,
+			},
+			Spec: kubernetesLeaseSpec{
+				HolderIdentity:       holder,
+				LeaseDurationSeconds: int(duration.Seconds()),
+				AcquireTime:          now.Format(kubernetesMicroTimeFormat),
+				RenewTime:            now.Format(kubernetesMicroTimeFormat),
+			},
+		}
+
+		if err = l.Client.Do(ctx, http.MethodPost, l.path(false), lease, nil); err != nil {
+			if IsKubernetesStatus(err, http.StatusConflict) {
+				return false, nil
+			}
+
+			return
+		}
+
+		return true, nil
+	}
+
+	if lease.Spec.HolderIdentity != l.observedSpec.HolderIdentity || lease.Spec.RenewTime != l.observedSpec.RenewTime || l.observedTime.IsZero() {
+		l.observedSpec = lease.Spec
+		l.observedTime = now
+	}
+
+	if lease.Spec.HolderIdentity != holder && lease.Spec.HolderIdentity != "" {
+		expiry := l.observedTime.Add(time.Duration(lease.Spec.LeaseDurationSeconds) * time.Second)
+
+		if now.Before(expiry) {
+			return false, nil
+		}
+	}
+
+	if lease.Spec.HolderIdentity != holder {
+		lease.Spec.AcquireTime = now.Format(kubernetesMicroTimeFormat)
+		lease.Spec.LeaseTransitions++
+	}
+
+	lease.Spec.HolderIdentity = holder
+	lease.Spec.LeaseDurationSeconds = int(duration.Seconds())
+	lease.Spec.RenewTime = now.Format(kubernetesMicroTimeFormat)
+
+	// The resource version in the lease makes the update fail with a conflict
+	// if someone else updated the lease in the meantime.
+	if err = l.Client.Do(ctx, http.MethodPut, l.path(true), lease, nil); err != nil {
+		if IsKubernetesStatus(err, http.StatusConflict) {
+			return false, nil
+		}
+
+		return
+	}
+
+	return true, nil
+}
+
+// Release the lease, if it is held by the specified holder.
+func (l *KubernetesLeaseLock) Release(ctx context.Context, holder string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("releasing lease %s/%s: %s", l.Namespace, l.Name, err)
+		}
+	}()
+
+	lease := &kubernetesLease{}
+
+	if err = l.Client.Do(ctx, http.MethodGet, l.path(true), nil, lease); err != nil {
+		return
+	}
+
+	if lease.Spec.HolderIdentity != holder {
+		return
+	}
+
+	lease.Spec.HolderIdentity = ""
+	lease.Spec.RenewTime = ""
+
+	return l.Client.Do(ctx, http.MethodPut, l.path(true), lease, nil)
+}
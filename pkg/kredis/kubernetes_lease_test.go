@@ -0,0 +1,134 @@
+package kredis
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeLeaseServer struct {
+	lock    sync.Mutex
+	lease   *kubernetesLease
+	version int
+}
+
+func (s *fakeLeaseServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		if s.lease == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(s.lease)
+	case http.MethodPost, http.MethodPut:
+		lease := &kubernetesLease{}
+		json.NewDecoder(r.Body).Decode(lease)
+
+		if (r.Method == http.MethodPost) != (s.lease == nil) || (s.lease != nil && s.lease.Metadata.ResourceVersion != lease.Metadata.ResourceVersion) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		s.version++
+		lease.Metadata.ResourceVersion = strconv.Itoa(s.version)
+		s.lease = lease
+		json.NewEncoder(w).Encode(s.lease)
+	}
+}
+
+func TestKubernetesLeaseLock(t *testing.T) {
+	server := httptest.NewServer(&fakeLeaseServer{})
+	defer server.Close()
+
+	now := time.Now().UTC()
+	lock := &KubernetesLeaseLock{
+		Client:    &KubernetesClient{BaseURL: server.URL},
+		Namespace: "default",
+		Name:      "kredis",
+		timeFunc:  func() time.Time { return now },
+	}
+	ctx := context.Background()
+
+	for _, step := range []struct {
+		Holder   string
+		Expected bool
+	}{
+		{"a", true},
+		{"a", true},
+		{"b", false},
+	} {
+		acquired, err := lock.Acquire(ctx, step.Holder, time.Second*10)
+
+		if err != nil {
+			t.Fatalf("expected no error but got: %s", err)
+		}
+
+		if acquired != step.Expected {
+			t.Errorf("expected %s to acquire the lock: %t, got: %t", step.Holder, step.Expected, acquired)
+		}
+	}
+
+	// Once the lease expires, another holder can take it.
+	now = now.Add(time.Second * 11)
+
+	if acquired, err := lock.Acquire(ctx, "b", time.Second*10); err != nil || !acquired {
+		t.Errorf("expected b to acquire the expired lock but got: %t, %v", acquired, err)
+	}
+
+	if err := lock.Release(ctx, "b"); err != nil {
+		t.Errorf("expected no error but got: %s", err)
+	}
+
+	if acquired, err := lock.Acquire(ctx, "a", time.Second*10); err != nil || !acquired {
+		t.Errorf("expected a to acquire the released lock but got: %t, %v", acquired, err)
+	}
+}
+
+func TestKubernetesLeaseLockClockSkew(t *testing.T) {
+	server := httptest.NewServer(&fakeLeaseServer{})
+	defer server.Close()
+
+	now := time.Now().UTC()
+	newLock := func(skew time.Duration) *KubernetesLeaseLock {
+		return &KubernetesLeaseLock{
+			Client:    &KubernetesClient{BaseURL: server.URL},
+			Namespace: "default",
+			Name:      "kredis",
+			timeFunc:  func() time.Time { return now.Add(skew) },
+		}
+	}
+
+	// The clock of "a" is an hour late, so the renew times it writes look
+	// expired to "b".
+	lockA := newLock(-time.Hour)
+	lockB := newLock(0)
+	ctx := context.Background()
+
+	for i := 0; i < 4; i++ {
+		if acquired, err := lockA.Acquire(ctx, "a", time.Second*10); err != nil || !acquired {
+			t.Fatalf("expected a to renew the lock at step %d but got: %t, %v", i, acquired, err)
+		}
+
+		if acquired, err := lockB.Acquire(ctx, "b", time.Second*10); err != nil || acquired {
+			t.Errorf("expected b not to acquire the renewed lock at step %d but got: %t, %v", i, acquired, err)
+		}
+
+		now = now.Add(time.Second * 5)
+	}
+
+	// Once "a" stops renewing the lease, it expires for "b".
+	now = now.Add(time.Second * 6)
+
+	if acquired, err := lockB.Acquire(ctx, "b", time.Second*10); err != nil || !acquired {
+		t.Errorf("expected b to acquire the expired lock but got: %t, %v", acquired, err)
+	}
+}
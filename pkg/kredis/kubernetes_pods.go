@@ -0,0 +1,140 @@
+package kredis
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+type kubernetesPod struct {
+	Metadata struct {
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Spec struct {
+		Hostname  string `json:"hostname"`
+		Subdomain string `json:"subdomain"`
+	} `json:"spec"`
+	Status struct {
+		PodIP string `json:"podIP"`
+	} `json:"status"`
+}
+
+type kubernetesPodList struct {
+	Items []kubernetesPod `json:"items"`
+}
+
+// A KubernetesPodsDiscoverer discovers master groups from the pods matching
+// a label selector.
+//
+// If GroupLabel is set, pods are grouped by the value of that label.
+// Otherwise, pods are grouped by ordinal, like a DNSDiscoverer does.
+//
+// Pods are addressed by their stable network identity if they have one
+// (that is, "<hostname>.<subdomain>" for StatefulSet pods), by their IP
+// otherwise.
+//
+// Pods are kept whether they are ready or being deleted, so that restarting a
+// pod doesn't change the master groups: a pod that can't be reached is
+// handled like any other unreachable instance. Only the pods that have no
+// address at all, which are the pods without a stable network identity that
+// were not scheduled yet, are ignored.
+type KubernetesPodsDiscoverer struct {
+	Client            *KubernetesClient
+	Namespace         string
+	LabelSelector     string
+	GroupLabel        string
+	InstancesPerGroup int
+	Port              string
+}
+
+// Discover returns the master groups from the pods.
+func (d *KubernetesPodsDiscoverer) Discover(ctx context.Context) (masterGroups []MasterGroup, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("discovering master groups from pods in %s: %s", d.Namespace, err)
+		}
+	}()
+
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods", d.Namespace)
+
+	if d.LabelSelector != "" {
+		path += "?" + url.Values{"labelSelector": {d.LabelSelector}}.Encode()
+	}
+
+	pods := &kubernetesPodList{}
+
+	if err = d.Client.Do(ctx, http.MethodGet, path, nil, pods); err != nil {
+		return
+	}
+
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Metadata.Name < pods.Items[j].Metadata.Name })
+
+	var items []kubernetesPod
+	var instances []RedisInstance
+
+	for _, pod := range pods.Items {
+		hostname := pod.Status.PodIP
+
+		if pod.Spec.Hostname != "" && pod.Spec.Subdomain != "" {
+			hostname = pod.Spec.Hostname + "." + pod.Spec.Subdomain
+		}
+
+		if hostname == "" {
+			continue
+		}
+
+		items = append(items, pod)
+		instances = append(instances, RedisInstance{Hostname: hostname, Port: d.Port})
+	}
+
+	if d.GroupLabel == "" {
+		ordinalInstances := make([]ordinalInstance, len(items))
+
+		for i, pod := range items {
+			if ordinalInstances[i].Ordinal, err = parseOrdinal(pod.Metadata.Name); err != nil {
+				return
+			}
+
+			ordinalInstances[i].Instance = instances[i]
+		}
+
+		return groupByOrdinal(ordinalInstances, d.InstancesPerGroup)
+	}
+
+	var groups []string
+	groupsByLabel := map[string][]ordinalInstance{}
+
+	for i, pod := range items {
+		value, ok := pod.Metadata.Labels[d.GroupLabel]
+
+		if !ok {
+			return nil, fmt.Errorf("pod %s has no \"%s\" label", pod.Metadata.Name, d.GroupLabel)
+		}
+
+		if _, ok := groupsByLabel[value]; !ok {
+			groups = append(groups, value)
+		}
+
+		// Within a group, pods are sorted by ordinal if they have one.
+		ordinal, _ := parseOrdinal(pod.Metadata.Name)
+		groupsByLabel[value] = append(groupsByLabel[value], ordinalInstance{Ordinal: ordinal, Instance: instances[i]})
+	}
+
+	sort.Strings(groups)
+	masterGroups = make([]MasterGroup, len(groups))
+
+	for i, group := range groups {
+		members := groupsByLabel[group]
+
+		sort.SliceStable(members, func(i, j int) bool { return members[i].Ordinal < members[j].Ordinal })
+
+		for _, member := range members {
+			masterGroups[i] = append(masterGroups[i], member.Instance)
+		}
+	}
+
+	return
+}
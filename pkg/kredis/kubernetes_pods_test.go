@@ -0,0 +1,86 @@
+package kredis
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func newKubernetesPod(name, hostname, subdomain, podIP string, labels map[string]string) kubernetesPod {
+	pod := kubernetesPod{}
+	pod.Metadata.Name = name
+	pod.Metadata.Labels = labels
+	pod.Spec.Hostname = hostname
+	pod.Spec.Subdomain = subdomain
+	pod.Status.PodIP = podIP
+
+	return pod
+}
+
+func TestKubernetesPodsDiscoverer(t *testing.T) {
+	pods := kubernetesPodList{
+		Items: []kubernetesPod{
+			newKubernetesPod("redis-2", "redis-2", "redis", "10.0.0.2", map[string]string{"shard": "b"}),
+			newKubernetesPod("redis-0", "redis-0", "redis", "10.0.0.0", map[string]string{"shard": "a"}),
+			newKubernetesPod("redis-1", "", "", "10.0.0.1", map[string]string{"shard": "a"}),
+			// A restarting pod has no IP but keeps its network identity.
+			newKubernetesPod("redis-3", "redis-3", "redis", "", map[string]string{"shard": "b"}),
+			newKubernetesPod("redis-4", "", "", "", map[string]string{"shard": "b"}),
+		},
+	}
+
+	var query string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		json.NewEncoder(w).Encode(pods)
+	}))
+	defer server.Close()
+
+	discoverer := &KubernetesPodsDiscoverer{
+		Client:            &KubernetesClient{BaseURL: server.URL},
+		Namespace:         "default",
+		LabelSelector:     "app=redis",
+		InstancesPerGroup: 2,
+		Port:              "6379",
+	}
+
+	masterGroups, err := discoverer.Discover(context.Background())
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	if expected := "labelSelector=app%3Dredis"; query != expected {
+		t.Errorf("expected: `%s`, got: `%s`", expected, query)
+	}
+
+	expected := []MasterGroup{
+		{{"redis-0.redis", "6379"}, {"10.0.0.1", "6379"}},
+		{{"redis-2.redis", "6379"}, {"redis-3.redis", "6379"}},
+	}
+
+	if !reflect.DeepEqual(masterGroups, expected) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, masterGroups)
+	}
+
+	discoverer.GroupLabel = "shard"
+	discoverer.InstancesPerGroup = 3
+
+	if masterGroups, err = discoverer.Discover(context.Background()); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	if !reflect.DeepEqual(masterGroups, expected) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, masterGroups)
+	}
+
+	discoverer.GroupLabel = "missing"
+
+	if _, err = discoverer.Discover(context.Background()); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,150 @@
+package kredis
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// A Lock represents a lease that can be held by a single holder at a time.
+type Lock interface {
+	// Acquire tries to acquire, or renew, the lock on behalf of the specified
+	// holder for the specified duration.
+	//
+	// It returns false if the lock is currently held by another holder.
+	Acquire(ctx context.Context, holder string, duration time.Duration) (bool, error)
+
+	// Release the lock, if it is held by the specified holder.
+	Release(ctx context.Context, holder string) error
+}
+
+// A LeaderElector ensures that only one of several processes sharing the same
+// Lock runs a function at a given time.
+type LeaderElector struct {
+	Lock          Lock
+	Identity      string
+	LeaseDuration time.Duration
+	RetryPeriod   time.Duration
+	Logger        log.Logger
+	lock          sync.Mutex
+	leader        bool
+}
+
+// IsLeader returns whether the elector currently holds the lock.
+func (e *LeaderElector) IsLeader() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.leader
+}
+
+func (e *LeaderElector) setLeader(leader bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.leader = leader
+}
+
+// Run campaigns for leadership until the context expires.
+//
+// Whenever leadership is acquired, f is called with a context that expires as
+// soon as leadership is lost. Leadership is lost when another process holds
+// the lock, or when one retry period is left before the lease expires, as
+// measured from the start of the last successful renewal. The latter doesn't
+// wait for pending renewal attempts. f must return within that retry period
+// so that two processes never run it concurrently.
+func (e *LeaderElector) Run(ctx context.Context, f func(context.Context)) {
+	ticker := time.NewTicker(e.RetryPeriod)
+	defer ticker.Stop()
+
+	var leaderCtx context.Context
+	var cancel func()
+	var done chan struct{}
+	var deadline *time.Timer
+
+	stepDown := func() {
+		if cancel != nil {
+			deadline.Stop()
+			cancel()
+			<-done
+			cancel = nil
+			done = nil
+			e.setLeader(false)
+			e.Logger.Log("event", "leadership lost", "identity", e.Identity)
+		}
+	}
+
+	defer func() {
+		wasLeader := cancel != nil
+		stepDown()
+
+		if wasLeader {
+			releaseCtx, releaseCancel := context.WithTimeout(context.Background(), e.RetryPeriod)
+			defer releaseCancel()
+
+			if err := e.Lock.Release(releaseCtx, e.Identity); err != nil {
+				e.Logger.Log("event", "leadership release failure", "identity", e.Identity, "error", err)
+			}
+		}
+	}()
+
+	for {
+		attemptedAt := time.Now()
+		acquireCtx, acquireCancel := context.WithTimeout(ctx, e.RetryPeriod)
+		acquired, err := e.Lock.Acquire(acquireCtx, e.Identity, e.LeaseDuration)
+		acquireCancel()
+
+		if err != nil {
+			e.Logger.Log("event", "leadership acquisition failure", "identity", e.Identity, "error", err)
+		}
+
+		// The deadline expired during the attempt: f is already stopping.
+		if cancel != nil && leaderCtx.Err() != nil {
+			stepDown()
+		}
+
+		// The lease was renewed no earlier than the attempt started, so the
+		// deadline is measured from there.
+		expiresIn := attemptedAt.Add(e.LeaseDuration - e.RetryPeriod).Sub(time.Now())
+
+		if acquired && err == nil {
+			if cancel == nil {
+				var leaderCancel func()
+				leaderCtx, leaderCancel = context.WithCancel(ctx)
+				cancel = leaderCancel
+				done = make(chan struct{})
+				deadline = time.AfterFunc(expiresIn, leaderCancel)
+				e.setLeader(true)
+				e.Logger.Log("event", "leadership acquired", "identity", e.Identity)
+
+				go func(leaderCtx context.Context, done chan struct{}) {
+					defer close(done)
+					f(leaderCtx)
+				}(leaderCtx, done)
+			} else {
+				deadline.Reset(expiresIn)
+			}
+		} else if err == nil {
+			// Another process holds the lock. Failed renewals are retried
+			// until the deadline instead.
+			stepDown()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			if ctx.Err() != nil || leaderCtx.Err() == nil {
+				// f returned on its own: give up the leadership so that
+				// another process can take over.
+				return
+			}
+
+			// The deadline expired: campaign again.
+			stepDown()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package kredis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+type fakeLock struct {
+	lock     sync.Mutex
+	holder   string
+	err      error
+	hung     bool
+	released bool
+}
+
+func (l *fakeLock) Acquire(ctx context.Context, holder string, duration time.Duration) (bool, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.hung {
+		l.lock.Unlock()
+		<-ctx.Done()
+		l.lock.Lock()
+
+		return false, ctx.Err()
+	}
+
+	if l.err != nil {
+		return false, l.err
+	}
+
+	if l.holder == "" {
+		l.holder = holder
+	}
+
+	return l.holder == holder, nil
+}
+
+func (l *fakeLock) Release(ctx context.Context, holder string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.holder == holder {
+		l.holder = ""
+		l.released = true
+	}
+
+	return nil
+}
+
+func (l *fakeLock) set(holder string, err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.holder = holder
+	l.err = err
+}
+
+func newTestLeaderElector(lock Lock, identity string) *LeaderElector {
+	return &LeaderElector{
+		Lock:          lock,
+		Identity:      identity,
+		LeaseDuration: time.Second,
+		RetryPeriod:   time.Millisecond,
+		Logger:        log.NewNopLogger(),
+	}
+}
+
+func TestLeaderElectorStandby(t *testing.T) {
+	lock := &fakeLock{holder: "other"}
+	elector := newTestLeaderElector(lock, "me")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+
+	elector.Run(ctx, func(context.Context) {
+		t.Error("expected the function not to be called")
+	})
+
+	if elector.IsLeader() {
+		t.Error("expected the elector not to be the leader")
+	}
+}
+
+func TestLeaderElectorLeadershipLost(t *testing.T) {
+	lock := &fakeLock{}
+	elector := newTestLeaderElector(lock, "me")
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go elector.Run(ctx, func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(stopped)
+	})
+
+	<-started
+
+	if !elector.IsLeader() {
+		t.Error("expected the elector to be the leader")
+	}
+
+	lock.set("other", nil)
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("expected the function to be stopped when leadership is lost")
+	}
+}
+
+func TestLeaderElectorRenewalFailure(t *testing.T) {
+	lock := &fakeLock{}
+	elector := newTestLeaderElector(lock, "me")
+	elector.LeaseDuration = time.Millisecond * 200
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go elector.Run(ctx, func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(stopped)
+	})
+
+	<-started
+
+	// A transient renewal failure doesn't interrupt the leader.
+	lock.set("me", errors.New("renewal failed"))
+	time.Sleep(elector.LeaseDuration / 4)
+	lock.set("me", nil)
+	time.Sleep(elector.LeaseDuration)
+
+	select {
+	case <-stopped:
+		t.Fatal("expected the function to keep running after a transient renewal failure")
+	default:
+	}
+
+	start := time.Now()
+	lock.set("me", errors.New("renewal failed"))
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("expected the function to be stopped when the lease can't be renewed")
+	}
+
+	if elapsed := time.Since(start); elapsed < elector.LeaseDuration/2 {
+		t.Errorf("expected the function to keep running while the lease is valid but it was stopped after %s", elapsed)
+	}
+}
+
+func TestLeaderElectorHungRenewal(t *testing.T) {
+	lock := &fakeLock{}
+	elector := newTestLeaderElector(lock, "me")
+	elector.LeaseDuration = time.Millisecond * 300
+	elector.RetryPeriod = time.Millisecond * 100
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go elector.Run(ctx, func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(stopped)
+	})
+
+	<-started
+
+	// Renewals fail quickly until shortly before the deadline, and then hang
+	// past the lease expiry.
+	start := time.Now()
+	lock.set("me", errors.New("renewal failed"))
+	time.Sleep(elector.LeaseDuration - elector.RetryPeriod*3/2)
+
+	lock.lock.Lock()
+	lock.hung = true
+	lock.lock.Unlock()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("expected the function to be stopped when the lease can't be renewed")
+	}
+
+	if elapsed := time.Since(start); elapsed > elector.LeaseDuration-elector.RetryPeriod/2 {
+		t.Errorf("expected the function to be stopped one retry period before the lease expires but it was stopped after %s", elapsed)
+	}
+}
+
+func TestLeaderElectorRelease(t *testing.T) {
+	lock := &fakeLock{}
+	elector := newTestLeaderElector(lock, "me")
+
+	elector.Run(context.Background(), func(context.Context) {})
+
+	if !lock.released {
+		t.Error("expected the lock to be released")
+	}
+
+	if elector.IsLeader() {
+		t.Error("expected the elector not to be the leader")
+	}
+}
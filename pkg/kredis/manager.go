@@ -2,8 +2,13 @@ package kredis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/garyburd/redigo/redis"
@@ -17,11 +22,17 @@ const (
 	// ManagerStateDNSResolution indicates that the manager is waiting for all
 	// its master groups addresses to resolve.
 	ManagerStateDNSResolution = "dns-resolution"
+	// ManagerStateFailover indicates that the manager is replacing failed
+	// masters.
+	ManagerStateFailover = "failover"
 	// ManagerStateMesh indicates that the manager is establishing the mesh.
 	ManagerStateMesh = "mesh"
 	// ManagerStateReplication indicates that the manager is setting-up
 	// replication.
 	ManagerStateReplication = "replication"
+	// ManagerStateMigrationRepair indicates that the manager is finishing or
+	// rolling back half-finished slot migrations.
+	ManagerStateMigrationRepair = "migration-repair"
 	// ManagerStateAssignation indicates that the manager is setting-up slots
 	// assignations.
 	ManagerStateAssignation = "assignation"
@@ -31,24 +42,174 @@ const (
 
 // A Manager is responsible for the coordination of Redis instances inside a
 // MasterGroup.
+//
+// CommandTimeout bounds every Redis command issued by the manager. If zero,
+// commands are only bounded by their context and the pool timeouts.
+//
+// BuildDatabase queries up to Concurrency instances in parallel, and gives up
+// on an instance after NodeTimeout, if non-zero. If AllowUnreachable is set,
+// instances that can't be queried are marked as unreachable in the database
+// instead of failing the build.
+//
+// ManagedSlots are the slots the manager assigns, and defaults to AllSlots if
+// empty. Other slots are left untouched.
+//
+// SlotsTolerance is the number of slots a master may own above or below its
+// share before slots are migrated to rebalance the cluster.
+//
+// Weights are the master group weights, keyed by any member of a master
+// group. If MaxMemoryWeights is set, master groups without an explicit weight
+// are weighted by the `maxmemory` setting of their master, in bytes: as other
+// weights are unitless, explicit weights may then only be 0, to drain a
+// master group.
+//
+// PinnedSlots are the slots pinned to master groups, keyed by any member of a
+// master group.
+//
+// If AutomaticFailover is set, masters that stay failed for FailoverDelay
+// are replaced by one of their replicas using that mode. As failed masters are
+// usually unreachable, it requires AllowUnreachable: otherwise, the database
+// can't be built while a master is down and no failover is ever planned.
+//
+// Masters that are not part of any master group are drained of their slots
+// once they have been unknown for DrainGracePeriod, and nodes that are not
+// part of any master group are forgotten once they have been unknown for
+// ForgetGracePeriod, across sync cycles.
+//
+// TopologyCommand selects the command used to fetch the cluster topology from
+// each instance, on top of `CLUSTER NODES`. If empty, only `CLUSTER NODES` is
+// used.
 type Manager struct {
 	SyncPeriod             time.Duration
 	WarningPeriodThreshold time.Duration
 	Logger                 log.Logger
 	Pool                   *Pool
 	MaxSlots               int
-	state                  ManagerState
+	ManagedSlots           HashSlots
+	SlotsTolerance         int
+	Weights                map[RedisInstance]float64
+	MaxMemoryWeights       bool
+	PinnedSlots            map[RedisInstance]HashSlots
+	AutomaticFailover      FailoverMode
+	FailoverDelay          time.Duration
+	DrainGracePeriod       time.Duration
+	ForgetGracePeriod      time.Duration
+	CommandTimeout         time.Duration
+	Concurrency            int
+	AllowUnreachable       bool
+	NodeTimeout            time.Duration
+	TopologyCommand        TopologyCommand
+	Metrics                *Metrics
+	lock                   sync.Mutex
+	status                 ManagerStatus
+	timeline               Timeline
+}
+
+// A ManagerStatus represents a snapshot of the manager status.
+type ManagerStatus struct {
+	// State is the current state of the manager.
+	State ManagerState
+	// LastTick is the last time the manager loop completed a sync cycle or
+	// an operation, or the time it started if it hasn't done either yet.
+	LastTick time.Time
+	// LastBuildError is the error returned by the last call to BuildDatabase,
+	// if any.
+	LastBuildError error
+	// Synced indicates whether BuildDatabase was called at least once.
+	Synced bool
+	// Degraded indicates whether the last database was built with
+	// unreachable instances.
+	Degraded bool
+}
+
+// Alive returns an error if the manager loop didn't tick for longer than
+// the specified threshold.
+func (s ManagerStatus) Alive(now time.Time, threshold time.Duration) error {
+	if s.LastTick.IsZero() {
+		return errors.New("manager is not running")
+	}
+
+	if elapsed := now.Sub(s.LastTick); elapsed > threshold {
+		return fmt.Errorf("manager did not tick for %s", elapsed)
+	}
+
+	return nil
+}
+
+// Ready returns an error if the last database build failed or if the
+// cluster is not stable.
+func (s ManagerStatus) Ready() error {
+	if !s.Synced {
+		return errors.New("manager did not sync yet")
+	}
+
+	if s.LastBuildError != nil {
+		return s.LastBuildError
+	}
+
+	if s.Degraded {
+		return errors.New("manager is running in degraded mode")
+	}
+
+	if s.State != ManagerStateStable {
+		return fmt.Errorf("manager is in state %s", s.State)
+	}
+
+	return nil
+}
+
+// Status returns a snapshot of the manager status.
+//
+// It is safe to call Status concurrently with Run.
+func (m *Manager) Status() ManagerStatus {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.status
 }
 
 func (m *Manager) setState(state ManagerState) {
-	if m.state != state {
-		m.state = state
+	m.lock.Lock()
+	changed := m.status.State != state
+	m.status.State = state
+	m.lock.Unlock()
+
+	if changed {
 		m.Logger.Log("event", "state changed", "state", state)
 	}
+
+	m.Metrics.SetState(state)
+}
+
+// getConn gets a connection to the specified Redis instance whose commands
+// honor the specified context and the command timeout.
+func (m *Manager) getConn(ctx context.Context, redisInstance RedisInstance) redis.Conn {
+	return newContextConn(ctx, m.Pool.Get(redisInstance), m.CommandTimeout)
+}
+
+func (m *Manager) tick(db *Database, buildErr error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.status.LastTick = time.Now().UTC()
+	m.status.LastBuildError = buildErr
+	m.status.Synced = true
+	m.status.Degraded = buildErr == nil && db.IsDegraded()
 }
 
-// Run the manager on the specified master groups until the context expires.
-func (m *Manager) Run(ctx context.Context, masterGroups []MasterGroup) {
+// heartbeat refreshes the manager liveness in the middle of a sync cycle.
+func (m *Manager) heartbeat() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.status.LastTick = time.Now().UTC()
+}
+
+// Run the manager on the master groups found by the specified discoverer
+// until the context expires.
+//
+// Master groups are discovered again on every sync cycle.
+func (m *Manager) Run(ctx context.Context, discoverer Discoverer) {
 	ticker := time.NewTicker(m.SyncPeriod)
 	defer ticker.Stop()
 
@@ -56,71 +217,78 @@ func (m *Manager) Run(ctx context.Context, masterGroups []MasterGroup) {
 		Threshold: m.WarningPeriodThreshold,
 	}
 
+	addError := func(err error) {
+		errorFeed.Add(err)
+		m.Metrics.ObserveSyncErrors(errorFeed.Count())
+	}
+
+	m.lock.Lock()
+	m.status = ManagerStatus{LastTick: time.Now().UTC()}
+	m.lock.Unlock()
+
 	m.setState(ManagerStateDNSResolution)
 
+	var previousMasterGroups []MasterGroup
+
 	for {
 		var err error
 		var db *Database
 
-		db, err = m.BuildDatabase(ctx, masterGroups)
+		start := time.Now()
+		masterGroups, err := discoverer.Discover(ctx)
+
+		if err == nil && len(masterGroups) == 0 {
+			err = errors.New("no master groups discovered")
+		}
+
+		if err == nil {
+			if !reflect.DeepEqual(masterGroups, previousMasterGroups) {
+				m.logMasterGroups(masterGroups)
+				previousMasterGroups = masterGroups
+
+				if err := m.Pool.Retain(masterGroupsInstances(masterGroups)); err != nil {
+					m.Logger.Log("event", "connections eviction failure", "error", err)
+				}
+			}
+
+			if db, err = m.BuildDatabase(ctx, masterGroups); err != nil {
+				m.Metrics.IncBuildDatabaseFailures()
+			}
+		}
+
+		m.tick(db, err)
 
 		if err != nil {
-			errorFeed.Add(err)
+			addError(err)
 		} else {
+			m.Metrics.ObserveDatabase(db)
 			operations := db.GetOperations()
+			m.timeline.Commit()
 
 			if len(operations) > 0 {
-				for _, operation := range operations {
-					switch operation := operation.(type) {
-					case MeetOperation:
-						m.setState(ManagerStateMesh)
-						m.Logger.Log("event", "cluster meet", "target", operation.Target, "other", operation.Other)
-						err = m.ClusterMeet(ctx, operation.Target, operation.Other)
-
-						if err != nil {
-							errorFeed.Add(err)
-						}
-					case ForgetOperation:
-						m.setState(ManagerStateMesh)
-						m.Logger.Log("event", "cluster forget", "target", operation.Target, "node-id", operation.NodeID)
-						err = m.ClusterForget(ctx, operation.Target, operation.NodeID)
-
-						if err != nil {
-							errorFeed.Add(err)
-						}
-					case ReplicateOperation:
-						m.setState(ManagerStateReplication)
-						m.Logger.Log("event", "cluster replicate", "target", operation.Target, "master", operation.Master)
-						err = m.ClusterReplicate(ctx, operation.Target, operation.MasterID)
-
-						if err != nil {
-							errorFeed.Add(err)
-						}
-					case AddSlotsOperation:
-						m.setState(ManagerStateAssignation)
-						m.Logger.Log("event", "cluster add slots", "target", operation.Target, "slots", operation.Slots)
-						err = m.ClusterAddSlots(ctx, operation.Target, operation.Slots)
-
-						if err != nil {
-							errorFeed.Add(err)
-						}
-					case MigrateSlotOperation:
-						m.setState(ManagerStateAssignation)
-						m.Logger.Log("event", "cluster migrate slot", "source", operation.Source, "destination", operation.Destination, "slot", operation.Slot)
-						err = m.ClusterMigrateSlots(ctx, operation.Source, operation.SourceID, operation.Destination, operation.DestinationID, HashSlots{operation.Slot})
-
-						if err != nil {
-							errorFeed.Add(err)
-						}
-					}
-				}
+				err = m.execute(ctx, operations, addError)
 			} else {
 				m.setState(ManagerStateStable)
 			}
+
+			// Unreachable instances are reported like any other error, so
+			// that a lasting degraded mode gets logged.
+			for _, unreachableErr := range db.GetUnreachableErrors() {
+				err = unreachableErr
+				addError(err)
+			}
+
+			if shortCircuited := db.GetShortCircuited(); len(shortCircuited) > 0 {
+				m.Logger.Log("event", "skipped redis instances with an open circuit", "redis-instances", fmt.Sprint(shortCircuited))
+			}
 		}
 
+		m.Metrics.ObserveSyncDuration(time.Since(start))
+		m.Metrics.ObservePool(m.Pool.Stats())
+
 		if err == nil {
 			errorFeed.Reset()
+			m.Metrics.ResetPendingSyncErrors()
 		} else if errors := errorFeed.PopErrors(); len(errors) != 0 {
 			m.Logger.Log("event", "synchronization errors", "errors-count", len(errors))
 
@@ -137,6 +305,47 @@ func (m *Manager) Run(ctx context.Context, masterGroups []MasterGroup) {
 	}
 }
 
+// execute runs the specified operations in order and returns the error of the
+// last one.
+//
+// A sync cycle may last much longer than the liveness threshold when it
+// migrates slots, so the liveness is refreshed after every operation.
+func (m *Manager) execute(ctx context.Context, operations []Operation, addError func(error)) (err error) {
+	for _, operation := range operations {
+		if ctx.Err() != nil {
+			break
+		}
+
+		m.Metrics.IncOperations(operation.Name())
+		m.setState(operation.State())
+		m.Logger.Log(append([]interface{}{"event", "cluster operation", "operation", operation.Name()}, operation.Describe()...)...)
+
+		if err = operation.Execute(ctx, m); err != nil {
+			addError(err)
+		}
+
+		m.heartbeat()
+	}
+
+	return
+}
+
+func masterGroupsInstances(masterGroups []MasterGroup) (redisInstances []RedisInstance) {
+	for _, masterGroup := range masterGroups {
+		redisInstances = append(redisInstances, masterGroup...)
+	}
+
+	return
+}
+
+func (m *Manager) logMasterGroups(masterGroups []MasterGroup) {
+	m.Logger.Log("event", "master groups", "count", len(masterGroups))
+
+	for i, masterGroup := range masterGroups {
+		m.Logger.Log("event", "master group", "index", i, "master-group", masterGroup)
+	}
+}
+
 // BuildDatabase build the cluster database by querying all the nodes.
 func (m *Manager) BuildDatabase(ctx context.Context, masterGroups []MasterGroup) (db *Database, err error) {
 	defer func() {
@@ -145,30 +354,175 @@ func (m *Manager) BuildDatabase(ctx context.Context, masterGroups []MasterGroup)
 		}
 	}()
 
-	db = &Database{ManagedSlots: AllSlots}
-	var nodes ClusterNodes
+	managedSlots := m.ManagedSlots
+
+	if len(managedSlots) == 0 {
+		managedSlots = AllSlots
+	}
+
+	db = &Database{ManagedSlots: managedSlots, SlotsTolerance: m.SlotsTolerance}
+	var redisInstances []RedisInstance
 
 	for _, masterGroup := range masterGroups {
 		if err = db.RegisterGroup(masterGroup); err != nil {
 			return
 		}
 
-		for _, redisInstance := range masterGroup {
-			nodes, err = m.GetClusterNodes(ctx, redisInstance)
+		redisInstances = append(redisInstances, masterGroup...)
+	}
 
-			if err != nil {
+	db.PinnedSlots = m.PinnedSlots
+	db.AutomaticFailover = m.AutomaticFailover
+	db.FailoverDelay = m.FailoverDelay
+	db.DrainGracePeriod = m.DrainGracePeriod
+	db.ForgetGracePeriod = m.ForgetGracePeriod
+	db.Timeline = &m.timeline
+
+	if err = db.CheckPinnedSlots(); err != nil {
+		return
+	}
+
+	// Instances whose circuit is open are not queried at all, so that they
+	// don't cost a full timeout on every cycle.
+	openCircuits := m.Pool.OpenCircuits()
+	queriedInstances := make([]RedisInstance, 0, len(redisInstances))
+
+	for _, redisInstance := range redisInstances {
+		until, open := openCircuits[redisInstance]
+
+		if !open {
+			queriedInstances = append(queriedInstances, redisInstance)
+			continue
+		}
+
+		circuitErr := &CircuitOpenError{RedisInstance: redisInstance, Until: until}
+
+		if !m.AllowUnreachable {
+			err = circuitErr
+			return
+		}
+
+		if err = db.MarkUnreachable(redisInstance, circuitErr); err != nil {
+			return
+		}
+	}
+
+	results := collectClusterNodes(ctx, queriedInstances, m.Concurrency, m.NodeTimeout, m.GetClusterNodes)
+
+	reachable := 0
+
+	for i, result := range results {
+		if result.Err != nil && m.AllowUnreachable {
+			if err = db.MarkUnreachable(queriedInstances[i], result.Err); err != nil {
 				return
 			}
 
-			err = db.Feed(redisInstance, nodes)
+			continue
+		}
+
+		if err = result.Err; err != nil {
+			return
+		}
+
+		reachable++
+
+		if err = db.Feed(queriedInstances[i], result.Nodes); err != nil {
+			return
+		}
+	}
+
+	if reachable == 0 && len(redisInstances) > 0 {
+		err = errors.New("no Redis instance is reachable")
+		return
+	}
+
+	db.Weights = make(map[RedisInstance]float64, len(m.Weights))
+
+	for redisInstance, weight := range m.Weights {
+		db.Weights[redisInstance] = weight
+	}
+
+	if m.MaxMemoryWeights {
+		err = m.addMaxMemoryWeights(ctx, db, masterGroups)
+	}
+
+	return
+}
+
+// addMaxMemoryWeights weights the master groups that don't have a weight yet
+// by the `maxmemory` setting of their master.
+//
+// Master groups without a reachable master are left unweighted.
+func (m *Manager) addMaxMemoryWeights(ctx context.Context, db *Database, masterGroups []MasterGroup) error {
+	for redisInstance, weight := range db.Weights {
+		if weight != 0 {
+			return fmt.Errorf("can't weight %s by %v along with maxmemory weights, which are in bytes", redisInstance, weight)
+		}
+	}
+
+	for _, masterGroup := range masterGroups {
+		weighted := false
+
+		for _, redisInstance := range masterGroup {
+			if _, ok := db.Weights[redisInstance]; ok {
+				weighted = true
+			}
+		}
+
+		if weighted {
+			continue
+		}
+
+		for _, redisInstance := range masterGroup {
+			id, ok := db.idByRedisInstance[redisInstance]
+
+			if !ok || !db.IsMaster(id) {
+				continue
+			}
+
+			maxMemory, err := m.GetMaxMemory(ctx, redisInstance)
 
 			if err != nil {
-				return
+				return err
+			}
+
+			if maxMemory <= 0 {
+				return fmt.Errorf("can't weight %s as maxmemory is not set", masterGroup)
 			}
+
+			db.Weights[redisInstance] = float64(maxMemory)
+
+			break
 		}
 	}
 
-	return
+	return nil
+}
+
+// GetMaxMemory gets the `maxmemory` setting of the specified redisInstance.
+func (m *Manager) GetMaxMemory(ctx context.Context, redisInstance RedisInstance) (maxMemory int64, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("fetching maxmemory for %s: %s", redisInstance, err)
+		}
+	}()
+
+	conn := m.getConn(ctx, redisInstance)
+	defer conn.Close()
+
+	var values []string
+	values, err = redis.Strings(conn.Do("CONFIG", "GET", "maxmemory"))
+
+	if err != nil {
+		return
+	}
+
+	if len(values) != 2 {
+		err = fmt.Errorf("expected a name and a value but got: %v", values)
+		return
+	}
+
+	return strconv.ParseInt(values[1], 10, 64)
 }
 
 // GetClusterNodes gets the cluster nodes for the specified redisInstance.
@@ -179,17 +533,71 @@ func (m *Manager) GetClusterNodes(ctx context.Context, redisInstance RedisInstan
 		}
 	}()
 
-	conn := m.Pool.Get(redisInstance)
+	conn := m.getConn(ctx, redisInstance)
 	defer conn.Close()
 
-	var data interface{}
-	data, err = conn.Do("CLUSTER", "NODES")
+	var data string
+	data, err = redis.String(conn.Do("CLUSTER", "NODES"))
 
 	if err != nil {
 		return
 	}
 
-	return ParseClusterNodes(string(data.([]byte)))
+	if nodes, err = ParseClusterNodes(data); err != nil {
+		return
+	}
+
+	var topologyNodes ClusterNodes
+
+	switch m.TopologyCommand {
+	case TopologyAuto:
+		topologyNodes, err = getClusterShards(conn)
+
+		// An error reply means the node doesn't support `CLUSTER SHARDS`.
+		if _, ok := err.(redis.Error); ok {
+			return nodes, nil
+		}
+
+		if err != nil {
+			return
+		}
+
+		mergeClusterShards(nodes, topologyNodes)
+	case TopologyShards:
+		if topologyNodes, err = getClusterShards(conn); err != nil {
+			return
+		}
+
+		mergeTopology(nodes, topologyNodes)
+	case TopologySlots:
+		if topologyNodes, err = getClusterSlots(conn); err != nil {
+			return
+		}
+
+		mergeTopology(nodes, topologyNodes)
+	}
+
+	return
+}
+
+func getClusterShards(conn redis.Conn) (ClusterNodes, error) {
+	reply, err := conn.Do("CLUSTER", "SHARDS")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseClusterShards(reply)
+}
+
+func getClusterSlots(conn redis.Conn) (ClusterNodes, error) {
+	reply, err := conn.Do("CLUSTER", "SLOTS")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseClusterSlots(reply)
 }
 
 // ClusterMeet causes a node to meet another one.
@@ -200,17 +608,17 @@ func (m *Manager) ClusterMeet(ctx context.Context, redisInstance RedisInstance,
 		}
 	}()
 
-	conn := m.Pool.Get(redisInstance)
+	conn := m.getConn(ctx, redisInstance)
 	defer conn.Close()
 
-	var ipAddresses []net.IP
-	ipAddresses, err = net.LookupIP(other.Hostname)
+	var ipAddresses []net.IPAddr
+	ipAddresses, err = net.DefaultResolver.LookupIPAddr(ctx, other.Hostname)
 
 	if err != nil {
 		return
 	}
 
-	_, err = conn.Do("CLUSTER", "MEET", ipAddresses[0], other.Port)
+	_, err = conn.Do("CLUSTER", "MEET", ipAddresses[0].IP, other.Port)
 
 	return
 }
@@ -223,7 +631,7 @@ func (m *Manager) ClusterForget(ctx context.Context, redisInstance RedisInstance
 		}
 	}()
 
-	conn := m.Pool.Get(redisInstance)
+	conn := m.getConn(ctx, redisInstance)
 	defer conn.Close()
 
 	_, err = conn.Do("CLUSTER", "FORGET", nodeID)
@@ -239,7 +647,7 @@ func (m *Manager) ClusterReplicate(ctx context.Context, redisInstance RedisInsta
 		}
 	}()
 
-	conn := m.Pool.Get(redisInstance)
+	conn := m.getConn(ctx, redisInstance)
 	defer conn.Close()
 
 	_, err = conn.Do("CLUSTER", "REPLICATE", master)
@@ -255,7 +663,7 @@ func (m *Manager) ClusterAddSlots(ctx context.Context, redisInstance RedisInstan
 		}
 	}()
 
-	conn := m.Pool.Get(redisInstance)
+	conn := m.getConn(ctx, redisInstance)
 	defer conn.Close()
 
 	for i := 0; i < len(slots); i += m.MaxSlots {
@@ -290,10 +698,52 @@ func (m *Manager) ClusterAddSlots(ctx context.Context, redisInstance RedisInstan
 	return
 }
 
+// ClusterStabilizeSlot clears the migrating or importing state of a slot on
+// a node.
+func (m *Manager) ClusterStabilizeSlot(ctx context.Context, redisInstance RedisInstance, slot int) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("stabilizing slot %d on %s: %s", slot, redisInstance, err)
+		}
+	}()
+
+	conn := m.getConn(ctx, redisInstance)
+	defer conn.Close()
+
+	_, err = conn.Do("CLUSTER", "SETSLOT", slot, "STABLE")
+
+	return
+}
+
+// ClusterFailover causes a replica to take over its master.
+func (m *Manager) ClusterFailover(ctx context.Context, redisInstance RedisInstance, mode FailoverMode) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("asking %s to take over its master: %s", redisInstance, err)
+		}
+	}()
+
+	conn := m.getConn(ctx, redisInstance)
+	defer conn.Close()
+
+	args := []interface{}{"FAILOVER"}
+
+	if mode != "" {
+		args = append(args, strings.ToUpper(string(mode)))
+	}
+
+	_, err = conn.Do("CLUSTER", args...)
+
+	return
+}
+
+// MigrateTimeout is the timeout of the MIGRATE commands that copy keys from an
+// instance to another, and of the rollback of a failed slot migration.
+const MigrateTimeout = time.Second * 30
+
 // ClusterMigrateSlots causes slots to migrate from one cluster node to another.
 func (m *Manager) ClusterMigrateSlots(ctx context.Context, source RedisInstance, sourceID ClusterNodeID, destination RedisInstance, destinationID ClusterNodeID, slots HashSlots) (err error) {
 	keysBatchSize := 10000
-	keysCopyTimeout := time.Second * 30
 
 	defer func() {
 		if err != nil {
@@ -301,29 +751,46 @@ func (m *Manager) ClusterMigrateSlots(ctx context.Context, source RedisInstance,
 		}
 	}()
 
-	sourceConn := m.Pool.Get(source)
+	sourceConn := m.getConn(ctx, source)
 	defer sourceConn.Close()
-	destConn := m.Pool.Get(destination)
+	destConn := m.getConn(ctx, destination)
 	defer destConn.Close()
 
+	// Rolling back must happen even if the context expired, so it uses its
+	// own connections and deadline.
+	stabilize := func(slot int, redisInstances ...RedisInstance) {
+		rollbackCtx, cancel := context.WithTimeout(context.Background(), MigrateTimeout)
+		defer cancel()
+
+		for _, redisInstance := range redisInstances {
+			conn := m.getConn(rollbackCtx, redisInstance)
+			conn.Do("CLUSTER", "SETSLOT", slot, "STABLE")
+			conn.Close()
+		}
+	}
+
 	for _, slot := range slots {
 		if _, err = destConn.Do("CLUSTER", "SETSLOT", slot, "IMPORTING", sourceID); err != nil {
 			return
 		}
 
 		if _, err = sourceConn.Do("CLUSTER", "SETSLOT", slot, "MIGRATING", destinationID); err != nil {
-			destConn.Do("CLUSTER", "SETSLOT", slot, "STABLE")
+			stabilize(slot, destination)
 			return
 		}
 
 		var keys []string
 
 		for {
+			if err = ctx.Err(); err != nil {
+				stabilize(slot, destination, source)
+				return
+			}
+
 			keys, err = redis.Strings(sourceConn.Do("CLUSTER", "GETKEYSINSLOT", slot, keysBatchSize))
 
 			if err != nil {
-				destConn.Do("CLUSTER", "SETSLOT", slot, "STABLE")
-				sourceConn.Do("CLUSTER", "SETSLOT", slot, "STABLE")
+				stabilize(slot, destination, source)
 				return
 			}
 
@@ -331,19 +798,26 @@ func (m *Manager) ClusterMigrateSlots(ctx context.Context, source RedisInstance,
 				break
 			}
 
+			// The MIGRATE timeout is expressed in milliseconds.
 			args := []interface{}{
-				destination.Hostname, destination.Port, "", 0, int(keysCopyTimeout.Seconds()), "REPLACE", "KEYS",
+				destination.Hostname, destination.Port, "", 0, int(MigrateTimeout / time.Millisecond), "REPLACE",
 			}
 
+			args = append(args, m.Pool.migrateAuthArgs()...)
+			args = append(args, "KEYS")
+
 			for _, key := range keys {
 				args = append(args, key)
 			}
 
 			if _, err = sourceConn.Do("MIGRATE", args...); err != nil {
-				destConn.Do("CLUSTER", "SETSLOT", slot, "STABLE")
-				sourceConn.Do("CLUSTER", "SETSLOT", slot, "STABLE")
+				stabilize(slot, destination, source)
 				return
 			}
+
+			// A slot may hold enough keys to migrate for longer than the
+			// liveness threshold.
+			m.heartbeat()
 		}
 
 		destConn.Do("CLUSTER", "SETSLOT", slot, "NODE", destinationID)
@@ -0,0 +1,24 @@
+package kredis
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestManagerBuildDatabaseMixedWeights(t *testing.T) {
+	redisInstance := serveFakeClusterNodes(t, "a 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-16383\n")
+
+	manager := &Manager{
+		Pool:             &Pool{},
+		Weights:          map[RedisInstance]float64{redisInstance: 2},
+		MaxMemoryWeights: true,
+	}
+	defer manager.Pool.Close()
+
+	_, err := manager.BuildDatabase(context.Background(), []MasterGroup{{redisInstance}})
+
+	if err == nil || !strings.Contains(err.Error(), "maxmemory weights") {
+		t.Errorf("expected a mixed weights error but got: %v", err)
+	}
+}
@@ -0,0 +1,312 @@
+package kredis
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// syncDurationBuckets are the upper bounds, in seconds, of the sync duration
+// histogram buckets.
+var syncDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// managerStates lists all the known manager states, so that the state gauge
+// always exports every one of them.
+var managerStates = []ManagerState{
+	ManagerStateDNSResolution,
+	ManagerStateFailover,
+	ManagerStateMesh,
+	ManagerStateReplication,
+	ManagerStateMigrationRepair,
+	ManagerStateAssignation,
+	ManagerStateStable,
+}
+
+type masterMetric struct {
+	redisInstance RedisInstance
+	nodeID        ClusterNodeID
+	slots         int
+	replicas      int
+}
+
+// Metrics collects the manager metrics and exports them in the Prometheus
+// text exposition format.
+//
+// A nil *Metrics is valid and discards everything.
+type Metrics struct {
+	lock                  sync.Mutex
+	state                 ManagerState
+	stateSince            time.Time
+	syncCount             int
+	syncDurationSum       float64
+	syncDurationBuckets   []int
+	buildDatabaseFailures int
+	operations            map[string]int
+	masters               []masterMetric
+	shortCircuited        int
+	syncErrors            int
+	pendingSyncErrors     int
+	pool                  map[RedisInstance]PoolStats
+	timeFunc              func() time.Time
+}
+
+func (m *Metrics) init() {
+	if m.timeFunc == nil {
+		m.timeFunc = func() time.Time { return time.Now().UTC() }
+	}
+
+	if m.syncDurationBuckets == nil {
+		m.syncDurationBuckets = make([]int, len(syncDurationBuckets))
+	}
+
+	if m.operations == nil {
+		m.operations = make(map[string]int)
+	}
+}
+
+// SetState records the current manager state.
+func (m *Metrics) SetState(state ManagerState) {
+	if m == nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.init()
+
+	if m.state != state {
+		m.state = state
+		m.stateSince = m.timeFunc()
+	}
+}
+
+// ObserveSyncDuration records the duration of a sync cycle.
+func (m *Metrics) ObserveSyncDuration(duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.init()
+
+	seconds := duration.Seconds()
+	m.syncCount++
+	m.syncDurationSum += seconds
+
+	for i, bound := range syncDurationBuckets {
+		if seconds <= bound {
+			m.syncDurationBuckets[i]++
+		}
+	}
+}
+
+// IncBuildDatabaseFailures records a failure to build the database.
+func (m *Metrics) IncBuildDatabaseFailures() {
+	if m == nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.buildDatabaseFailures++
+}
+
+// IncOperations records the execution of an operation of the specified type.
+func (m *Metrics) IncOperations(operationType string) {
+	if m == nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.init()
+	m.operations[operationType]++
+}
+
+// ObserveSyncErrors records a synchronization error and the number of errors
+// currently aggregated by the error feed.
+func (m *Metrics) ObserveSyncErrors(pending int) {
+	if m == nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.syncErrors++
+	m.pendingSyncErrors = pending
+}
+
+// ResetPendingSyncErrors records that the error feed was emptied.
+func (m *Metrics) ResetPendingSyncErrors() {
+	if m == nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.pendingSyncErrors = 0
+}
+
+// ObserveDatabase records the slots and replicas of every master in the
+// specified database, and the number of instances it skipped because their
+// circuit was open.
+func (m *Metrics) ObserveDatabase(db *Database) {
+	if m == nil {
+		return
+	}
+
+	masters := make([]masterMetric, 0, len(db.masters))
+	foreignNodes := db.getForeignNodes()
+
+	for _, nodeID := range db.masters {
+		masters = append(masters, masterMetric{
+			redisInstance: db.getRedisInstance(nodeID, foreignNodes),
+			nodeID:        nodeID,
+			slots:         len(db.slotsByID[nodeID]),
+			replicas:      len(db.slavesByID[nodeID]),
+		})
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.masters = masters
+	m.shortCircuited = len(db.GetShortCircuited())
+}
+
+// ObservePool records the connections statistics of the pool.
+func (m *Metrics) ObservePool(stats map[RedisInstance]PoolStats) {
+	if m == nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.pool = stats
+}
+
+func writeMetricHeader(buffer *bytes.Buffer, name, metricType, help string) {
+	fmt.Fprintf(buffer, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(buffer, "# TYPE %s %s\n", name, metricType)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func (m *Metrics) write(buffer *bytes.Buffer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.init()
+
+	writeMetricHeader(buffer, "kredis_manager_state", "gauge", "The current state of the manager.")
+
+	for _, state := range managerStates {
+		value := 0
+
+		if m.state == state {
+			value = 1
+		}
+
+		fmt.Fprintf(buffer, "kredis_manager_state{state=%q} %d\n", state, value)
+	}
+
+	writeMetricHeader(buffer, "kredis_manager_state_duration_seconds", "gauge", "The time spent in the current state of the manager.")
+
+	var stateDuration float64
+
+	if m.state != "" {
+		stateDuration = m.timeFunc().Sub(m.stateSince).Seconds()
+	}
+
+	fmt.Fprintf(buffer, "kredis_manager_state_duration_seconds %s\n", formatFloat(stateDuration))
+
+	writeMetricHeader(buffer, "kredis_sync_duration_seconds", "histogram", "The duration of the manager sync cycles.")
+
+	for i, bound := range syncDurationBuckets {
+		fmt.Fprintf(buffer, "kredis_sync_duration_seconds_bucket{le=%q} %d\n", formatFloat(bound), m.syncDurationBuckets[i])
+	}
+
+	fmt.Fprintf(buffer, "kredis_sync_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.syncCount)
+	fmt.Fprintf(buffer, "kredis_sync_duration_seconds_sum %s\n", formatFloat(m.syncDurationSum))
+	fmt.Fprintf(buffer, "kredis_sync_duration_seconds_count %d\n", m.syncCount)
+
+	writeMetricHeader(buffer, "kredis_build_database_failures_total", "counter", "The number of times the cluster database could not be built.")
+	fmt.Fprintf(buffer, "kredis_build_database_failures_total %d\n", m.buildDatabaseFailures)
+
+	writeMetricHeader(buffer, "kredis_operations_total", "counter", "The number of operations run, by type.")
+
+	operationTypes := make([]string, 0, len(m.operations))
+
+	for operationType := range m.operations {
+		operationTypes = append(operationTypes, operationType)
+	}
+
+	sort.Strings(operationTypes)
+
+	for _, operationType := range operationTypes {
+		fmt.Fprintf(buffer, "kredis_operations_total{type=%q} %d\n", operationType, m.operations[operationType])
+	}
+
+	writeMetricHeader(buffer, "kredis_master_slots", "gauge", "The number of slots owned by each master.")
+
+	for _, master := range m.masters {
+		fmt.Fprintf(buffer, "kredis_master_slots{master=%q,node_id=%q} %d\n", master.redisInstance, master.nodeID, master.slots)
+	}
+
+	writeMetricHeader(buffer, "kredis_master_replicas", "gauge", "The number of replicas of each master.")
+
+	for _, master := range m.masters {
+		fmt.Fprintf(buffer, "kredis_master_replicas{master=%q,node_id=%q} %d\n", master.redisInstance, master.nodeID, master.replicas)
+	}
+
+	writeMetricHeader(buffer, "kredis_short_circuited_instances", "gauge", "The number of Redis instances skipped by the last sync cycle because their circuit was open.")
+	fmt.Fprintf(buffer, "kredis_short_circuited_instances %d\n", m.shortCircuited)
+
+	writeMetricHeader(buffer, "kredis_sync_errors_total", "counter", "The number of synchronization errors.")
+	fmt.Fprintf(buffer, "kredis_sync_errors_total %d\n", m.syncErrors)
+
+	writeMetricHeader(buffer, "kredis_sync_errors_pending", "gauge", "The number of synchronization errors currently aggregated by the error feed.")
+	fmt.Fprintf(buffer, "kredis_sync_errors_pending %d\n", m.pendingSyncErrors)
+
+	writeMetricHeader(buffer, "kredis_pool_connections", "gauge", "The number of connections to each Redis instance, by state.")
+
+	redisInstances := make([]RedisInstance, 0, len(m.pool))
+
+	for redisInstance := range m.pool {
+		redisInstances = append(redisInstances, redisInstance)
+	}
+
+	sort.Slice(redisInstances, func(i, j int) bool { return redisInstances[i].String() < redisInstances[j].String() })
+
+	for _, redisInstance := range redisInstances {
+		stats := m.pool[redisInstance]
+		fmt.Fprintf(buffer, "kredis_pool_connections{redis_instance=%q,state=\"active\"} %d\n", redisInstance, stats.Active)
+		fmt.Fprintf(buffer, "kredis_pool_connections{redis_instance=%q,state=\"idle\"} %d\n", redisInstance, stats.Idle)
+	}
+}
+
+// ServeHTTP exports the metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buffer bytes.Buffer
+
+	if m != nil {
+		m.write(&buffer)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buffer.Bytes())
+}
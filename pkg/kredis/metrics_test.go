@@ -0,0 +1,111 @@
+package kredis
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsNil(t *testing.T) {
+	var metrics *Metrics
+
+	metrics.SetState(ManagerStateStable)
+	metrics.ObserveSyncDuration(time.Second)
+	metrics.IncBuildDatabaseFailures()
+	metrics.IncOperations("meet")
+	metrics.ObserveSyncErrors(1)
+	metrics.ResetPendingSyncErrors()
+	metrics.ObserveDatabase(&Database{})
+	metrics.ObservePool(map[RedisInstance]PoolStats{riA: {Active: 1}})
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if body := recorder.Body.String(); body != "" {
+		t.Errorf("expected an empty body but got:\n%s", body)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	now := time.Now().UTC()
+
+	metrics := &Metrics{
+		timeFunc: func() time.Time { return now },
+	}
+
+	database := &Database{}
+	database.RegisterGroup(group)
+	database.Feed(riA, nodesA)
+	database.Feed(riB, nodesB)
+	database.Feed(riC, nodesC)
+
+	metrics.SetState(ManagerStateMesh)
+	now = now.Add(time.Second * 3)
+	metrics.ObserveSyncDuration(time.Millisecond * 20)
+	metrics.ObserveSyncDuration(time.Second * 2)
+	metrics.IncBuildDatabaseFailures()
+	metrics.IncOperations("meet")
+	metrics.IncOperations("meet")
+	metrics.IncOperations("forget")
+	metrics.ObserveSyncErrors(1)
+	metrics.ObserveSyncErrors(2)
+	metrics.ObserveDatabase(database)
+	metrics.ObservePool(map[RedisInstance]PoolStats{riA: {Active: 3, Idle: 2}})
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+
+	for _, expected := range []string{
+		`kredis_manager_state{state="mesh"} 1`,
+		`kredis_manager_state{state="stable"} 0`,
+		`kredis_manager_state_duration_seconds 3`,
+		`kredis_sync_duration_seconds_bucket{le="0.01"} 0`,
+		`kredis_sync_duration_seconds_bucket{le="0.025"} 1`,
+		`kredis_sync_duration_seconds_bucket{le="2.5"} 2`,
+		`kredis_sync_duration_seconds_bucket{le="+Inf"} 2`,
+		`kredis_sync_duration_seconds_count 2`,
+		`kredis_build_database_failures_total 1`,
+		`kredis_operations_total{type="forget"} 1`,
+		`kredis_operations_total{type="meet"} 2`,
+		`kredis_master_slots{master="a:",node_id="a"} 3`,
+		`kredis_master_replicas{master="a:",node_id="a"} 2`,
+		`kredis_short_circuited_instances 0`,
+		`kredis_sync_errors_total 2`,
+		`kredis_sync_errors_pending 2`,
+		`kredis_pool_connections{redis_instance="a:",state="active"} 3`,
+		`kredis_pool_connections{redis_instance="a:",state="idle"} 2`,
+	} {
+		if !strings.Contains(body, expected+"\n") {
+			t.Errorf("expected the metrics to contain:\n%s\ngot:\n%s", expected, body)
+		}
+	}
+
+	metrics.ResetPendingSyncErrors()
+	recorder = httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if body = recorder.Body.String(); !strings.Contains(body, "kredis_sync_errors_pending 0\n") {
+		t.Errorf("expected no pending errors but got:\n%s", body)
+	}
+}
+
+func TestMetricsShortCircuited(t *testing.T) {
+	metrics := &Metrics{}
+
+	database := &Database{}
+	database.RegisterGroup(group)
+	database.Feed(riA, nodesA)
+	database.MarkUnreachable(riB, &CircuitOpenError{RedisInstance: riB})
+	database.MarkUnreachable(riC, &CircuitOpenError{RedisInstance: riC})
+
+	metrics.ObserveDatabase(database)
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if body := recorder.Body.String(); !strings.Contains(body, "kredis_short_circuited_instances 2\n") {
+		t.Errorf("expected two short-circuited instances but got:\n%s", body)
+	}
+}
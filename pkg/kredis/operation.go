@@ -0,0 +1,266 @@
+package kredis
+
+import (
+	"context"
+	"fmt"
+)
+
+// An Executor executes cluster commands on behalf of operations.
+//
+// Manager is the default Executor. It also implements SlotStabilizer and
+// FailoverExecutor.
+type Executor interface {
+	ClusterMeet(ctx context.Context, redisInstance RedisInstance, other RedisInstance) error
+	ClusterForget(ctx context.Context, redisInstance RedisInstance, nodeID ClusterNodeID) error
+	ClusterReplicate(ctx context.Context, redisInstance RedisInstance, master ClusterNodeID) error
+	ClusterAddSlots(ctx context.Context, redisInstance RedisInstance, slots HashSlots) error
+	ClusterMigrateSlots(ctx context.Context, source RedisInstance, sourceID ClusterNodeID, destination RedisInstance, destinationID ClusterNodeID, slots HashSlots) error
+}
+
+// A SlotStabilizer clears the migrating or importing state of slots.
+//
+// Executors that don't implement it can't execute StabilizeSlotOperation.
+type SlotStabilizer interface {
+	ClusterStabilizeSlot(ctx context.Context, redisInstance RedisInstance, slot int) error
+}
+
+// A FailoverExecutor promotes replicas in place of their master.
+//
+// Executors that don't implement it can't execute FailoverOperation.
+type FailoverExecutor interface {
+	ClusterFailover(ctx context.Context, redisInstance RedisInstance, mode FailoverMode) error
+}
+
+// Operation represents a cluster operation.
+//
+// Custom operations can be implemented outside of this package: their Execute
+// method can type-assert the executor to access additional capabilities.
+type Operation interface {
+	// Name returns a short, stable name for the kind of operation.
+	Name() string
+
+	// Describe returns the key-value pairs that describe the operation, in a
+	// form suitable for logging.
+	Describe() []interface{}
+
+	// State returns the manager state the operation belongs to.
+	State() ManagerState
+
+	// Explain returns the reason why the operation was planned.
+	Explain() Reason
+
+	// Execute the operation.
+	Execute(ctx context.Context, executor Executor) error
+}
+
+// A MeetOperation indicates that a node must meet another.
+type MeetOperation struct {
+	Target RedisInstance
+	Other  RedisInstance
+	Reason Reason
+}
+
+// Name returns "meet".
+func (o MeetOperation) Name() string { return "meet" }
+
+// Describe the operation.
+func (o MeetOperation) Describe() []interface{} {
+	return []interface{}{"target", o.Target, "other", o.Other, "reason", o.Reason}
+}
+
+// State returns ManagerStateMesh.
+func (o MeetOperation) State() ManagerState { return ManagerStateMesh }
+
+// Explain returns the reason why the operation was planned.
+func (o MeetOperation) Explain() Reason { return o.Reason }
+
+// Execute the operation.
+func (o MeetOperation) Execute(ctx context.Context, executor Executor) error {
+	return executor.ClusterMeet(ctx, o.Target, o.Other)
+}
+
+// A ForgetOperation indicates that a node must be forgotten.
+type ForgetOperation struct {
+	Target RedisInstance
+	NodeID ClusterNodeID
+	Reason Reason
+}
+
+// Name returns "forget".
+func (o ForgetOperation) Name() string { return "forget" }
+
+// Describe the operation.
+func (o ForgetOperation) Describe() []interface{} {
+	return []interface{}{"target", o.Target, "node-id", o.NodeID, "reason", o.Reason}
+}
+
+// State returns ManagerStateMesh.
+func (o ForgetOperation) State() ManagerState { return ManagerStateMesh }
+
+// Explain returns the reason why the operation was planned.
+func (o ForgetOperation) Explain() Reason { return o.Reason }
+
+// Execute the operation.
+func (o ForgetOperation) Execute(ctx context.Context, executor Executor) error {
+	return executor.ClusterForget(ctx, o.Target, o.NodeID)
+}
+
+// A ReplicateOperation indicates that a node must replicate another.
+type ReplicateOperation struct {
+	Target   RedisInstance
+	Master   RedisInstance
+	MasterID ClusterNodeID
+	Reason   Reason
+}
+
+// Name returns "replicate".
+func (o ReplicateOperation) Name() string { return "replicate" }
+
+// Describe the operation.
+func (o ReplicateOperation) Describe() []interface{} {
+	return []interface{}{"target", o.Target, "master", o.Master, "master-id", o.MasterID, "reason", o.Reason}
+}
+
+// State returns ManagerStateReplication.
+func (o ReplicateOperation) State() ManagerState { return ManagerStateReplication }
+
+// Explain returns the reason why the operation was planned.
+func (o ReplicateOperation) Explain() Reason { return o.Reason }
+
+// Execute the operation.
+func (o ReplicateOperation) Execute(ctx context.Context, executor Executor) error {
+	return executor.ClusterReplicate(ctx, o.Target, o.MasterID)
+}
+
+// AddSlotsOperation adds slots to a given instance.
+type AddSlotsOperation struct {
+	Target RedisInstance
+	Slots  HashSlots
+	Reason Reason
+}
+
+// Name returns "add-slots".
+func (o AddSlotsOperation) Name() string { return "add-slots" }
+
+// Describe the operation.
+func (o AddSlotsOperation) Describe() []interface{} {
+	return []interface{}{"target", o.Target, "slots", o.Slots, "reason", o.Reason}
+}
+
+// State returns ManagerStateAssignation.
+func (o AddSlotsOperation) State() ManagerState { return ManagerStateAssignation }
+
+// Explain returns the reason why the operation was planned.
+func (o AddSlotsOperation) Explain() Reason { return o.Reason }
+
+// Execute the operation.
+func (o AddSlotsOperation) Execute(ctx context.Context, executor Executor) error {
+	return executor.ClusterAddSlots(ctx, o.Target, o.Slots)
+}
+
+// MigrateSlotOperation migrates a slot from an instance to another.
+//
+// Repair is set when the operation completes a migration that was left open,
+// rather than one planned to assign slots.
+type MigrateSlotOperation struct {
+	Source        RedisInstance
+	SourceID      ClusterNodeID
+	Destination   RedisInstance
+	DestinationID ClusterNodeID
+	Slot          int
+	Repair        bool
+	Reason        Reason
+}
+
+// Name returns "migrate-slot".
+func (o MigrateSlotOperation) Name() string { return "migrate-slot" }
+
+// Describe the operation.
+func (o MigrateSlotOperation) Describe() []interface{} {
+	return []interface{}{"source", o.Source, "destination", o.Destination, "slot", o.Slot, "reason", o.Reason}
+}
+
+// State returns ManagerStateMigrationRepair for repairs, and
+// ManagerStateAssignation otherwise.
+func (o MigrateSlotOperation) State() ManagerState {
+	if o.Repair {
+		return ManagerStateMigrationRepair
+	}
+
+	return ManagerStateAssignation
+}
+
+// Explain returns the reason why the operation was planned.
+func (o MigrateSlotOperation) Explain() Reason { return o.Reason }
+
+// Execute the operation.
+func (o MigrateSlotOperation) Execute(ctx context.Context, executor Executor) error {
+	return executor.ClusterMigrateSlots(ctx, o.Source, o.SourceID, o.Destination, o.DestinationID, HashSlots{o.Slot})
+}
+
+// StabilizeSlotOperation clears the migrating or importing state of a slot on
+// an instance.
+type StabilizeSlotOperation struct {
+	Target RedisInstance
+	Slot   int
+	Reason Reason
+}
+
+// Name returns "stabilize-slot".
+func (o StabilizeSlotOperation) Name() string { return "stabilize-slot" }
+
+// Describe the operation.
+func (o StabilizeSlotOperation) Describe() []interface{} {
+	return []interface{}{"target", o.Target, "slot", o.Slot, "reason", o.Reason}
+}
+
+// State returns ManagerStateMigrationRepair.
+func (o StabilizeSlotOperation) State() ManagerState { return ManagerStateMigrationRepair }
+
+// Explain returns the reason why the operation was planned.
+func (o StabilizeSlotOperation) Explain() Reason { return o.Reason }
+
+// Execute the operation.
+func (o StabilizeSlotOperation) Execute(ctx context.Context, executor Executor) error {
+	slotStabilizer, ok := executor.(SlotStabilizer)
+
+	if !ok {
+		return fmt.Errorf("%T can't stabilize slots", executor)
+	}
+
+	return slotStabilizer.ClusterStabilizeSlot(ctx, o.Target, o.Slot)
+}
+
+// A FailoverOperation indicates that a replica must take over its failed
+// master.
+type FailoverOperation struct {
+	Target   RedisInstance
+	MasterID ClusterNodeID
+	Mode     FailoverMode
+	Reason   Reason
+}
+
+// Name returns "failover".
+func (o FailoverOperation) Name() string { return "failover" }
+
+// Describe the operation.
+func (o FailoverOperation) Describe() []interface{} {
+	return []interface{}{"target", o.Target, "master-id", o.MasterID, "mode", o.Mode, "reason", o.Reason}
+}
+
+// State returns ManagerStateFailover.
+func (o FailoverOperation) State() ManagerState { return ManagerStateFailover }
+
+// Explain returns the reason why the operation was planned.
+func (o FailoverOperation) Explain() Reason { return o.Reason }
+
+// Execute the operation.
+func (o FailoverOperation) Execute(ctx context.Context, executor Executor) error {
+	failoverExecutor, ok := executor.(FailoverExecutor)
+
+	if !ok {
+		return fmt.Errorf("%T can't execute failovers", executor)
+	}
+
+	return failoverExecutor.ClusterFailover(ctx, o.Target, o.Mode)
+}
@@ -0,0 +1,141 @@
+package kredis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type recordingExecutor struct {
+	calls [][]interface{}
+}
+
+func (e *recordingExecutor) ClusterMeet(ctx context.Context, redisInstance RedisInstance, other RedisInstance) error {
+	e.calls = append(e.calls, []interface{}{"meet", redisInstance, other})
+	return nil
+}
+
+func (e *recordingExecutor) ClusterForget(ctx context.Context, redisInstance RedisInstance, nodeID ClusterNodeID) error {
+	e.calls = append(e.calls, []interface{}{"forget", redisInstance, nodeID})
+	return nil
+}
+
+func (e *recordingExecutor) ClusterReplicate(ctx context.Context, redisInstance RedisInstance, master ClusterNodeID) error {
+	e.calls = append(e.calls, []interface{}{"replicate", redisInstance, master})
+	return nil
+}
+
+func (e *recordingExecutor) ClusterAddSlots(ctx context.Context, redisInstance RedisInstance, slots HashSlots) error {
+	e.calls = append(e.calls, []interface{}{"add-slots", redisInstance, slots})
+	return nil
+}
+
+func (e *recordingExecutor) ClusterMigrateSlots(ctx context.Context, source RedisInstance, sourceID ClusterNodeID, destination RedisInstance, destinationID ClusterNodeID, slots HashSlots) error {
+	e.calls = append(e.calls, []interface{}{"migrate-slots", source, sourceID, destination, destinationID, slots})
+	return nil
+}
+
+func (e *recordingExecutor) ClusterStabilizeSlot(ctx context.Context, redisInstance RedisInstance, slot int) error {
+	e.calls = append(e.calls, []interface{}{"stabilize-slot", redisInstance, slot})
+	return nil
+}
+
+func (e *recordingExecutor) ClusterFailover(ctx context.Context, redisInstance RedisInstance, mode FailoverMode) error {
+	e.calls = append(e.calls, []interface{}{"failover", redisInstance, mode})
+	return nil
+}
+
+func TestOperationsExecute(t *testing.T) {
+	testCases := []struct {
+		Operation    Operation
+		ExpectedCall []interface{}
+		State        ManagerState
+	}{
+		{
+			MeetOperation{Target: riA, Other: riB},
+			[]interface{}{"meet", riA, riB},
+			ManagerStateMesh,
+		},
+		{
+			ForgetOperation{Target: riA, NodeID: "b"},
+			[]interface{}{"forget", riA, ClusterNodeID("b")},
+			ManagerStateMesh,
+		},
+		{
+			ReplicateOperation{Target: riB, Master: riA, MasterID: "a"},
+			[]interface{}{"replicate", riB, ClusterNodeID("a")},
+			ManagerStateReplication,
+		},
+		{
+			AddSlotsOperation{Target: riA, Slots: HashSlots{1, 2}},
+			[]interface{}{"add-slots", riA, HashSlots{1, 2}},
+			ManagerStateAssignation,
+		},
+		{
+			MigrateSlotOperation{Source: riA, SourceID: "a", Destination: riB, DestinationID: "b", Slot: 3},
+			[]interface{}{"migrate-slots", riA, ClusterNodeID("a"), riB, ClusterNodeID("b"), HashSlots{3}},
+			ManagerStateAssignation,
+		},
+		{
+			MigrateSlotOperation{Source: riA, SourceID: "a", Destination: riB, DestinationID: "b", Slot: 3, Repair: true},
+			[]interface{}{"migrate-slots", riA, ClusterNodeID("a"), riB, ClusterNodeID("b"), HashSlots{3}},
+			ManagerStateMigrationRepair,
+		},
+		{
+			StabilizeSlotOperation{Target: riA, Slot: 3},
+			[]interface{}{"stabilize-slot", riA, 3},
+			ManagerStateMigrationRepair,
+		},
+		{
+			FailoverOperation{Target: riB, MasterID: "a", Mode: FailoverForce},
+			[]interface{}{"failover", riB, FailoverForce},
+			ManagerStateFailover,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Operation.Name(), func(t *testing.T) {
+			executor := &recordingExecutor{}
+
+			if err := testCase.Operation.Execute(context.Background(), executor); err != nil {
+				t.Fatalf("expected no error but got: %s", err)
+			}
+
+			if len(executor.calls) != 1 || !reflect.DeepEqual(executor.calls[0], testCase.ExpectedCall) {
+				t.Errorf("expected:\n%v\ngot:\n%v", testCase.ExpectedCall, executor.calls)
+			}
+
+			if state := testCase.Operation.State(); state != testCase.State {
+				t.Errorf("expected state %s but got %s", testCase.State, state)
+			}
+
+			if keyvals := testCase.Operation.Describe(); len(keyvals)%2 != 0 {
+				t.Errorf("expected key-value pairs but got: %v", keyvals)
+			}
+		})
+	}
+}
+
+// coreExecutor only implements the Executor methods.
+type coreExecutor struct {
+	Executor
+}
+
+func TestOperationsExecuteUnsupported(t *testing.T) {
+	for _, operation := range []Operation{
+		StabilizeSlotOperation{Target: riA, Slot: 3},
+		FailoverOperation{Target: riB, MasterID: "a", Mode: FailoverForce},
+	} {
+		t.Run(operation.Name(), func(t *testing.T) {
+			executor := &recordingExecutor{}
+
+			if err := operation.Execute(context.Background(), coreExecutor{executor}); err == nil {
+				t.Error("expected an error")
+			}
+
+			if len(executor.calls) != 0 {
+				t.Errorf("expected no calls but got: %v", executor.calls)
+			}
+		})
+	}
+}
@@ -2,7 +2,9 @@ package kredis
 
 import (
 	"bytes"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"sync"
 	"time"
 
@@ -10,13 +12,107 @@ import (
 )
 
 // A Pool represents a pool of Redis connection pools.
+//
+// A zero ConnectTimeout, ReadTimeout or WriteTimeout means no timeout.
+//
+// If Password is set, connections are authenticated right after being
+// established, as Username if it is set too (Redis 6 ACLs).
+//
+// If TLSConfig is set, connections use TLS. Its server name defaults to the
+// hostname of each instance. Redis instances running with "tls-cluster yes"
+// also use TLS for the cluster bus and MIGRATE, so instances must then be
+// specified with their TLS port.
+//
+// Idle connections are checked with a PING before being reused if they were
+// idle for longer than HealthCheckPeriod. A zero HealthCheckPeriod disables
+// the checks, as checking every idle connection would double the round trips
+// of every command.
+//
+// After CircuitBreakerThreshold consecutive failures to connect to an
+// instance, or connections to it breaking, its circuit opens: connections
+// to it fail immediately with a CircuitOpenError for
+// CircuitBreakerBackoff, doubling on every subsequent failure up to
+// CircuitBreakerMaxBackoff. A zero CircuitBreakerThreshold disables circuit
+// breaking.
 type Pool struct {
-	lock        sync.Mutex
-	pools       map[RedisInstance]*redis.Pool
-	MaxIdle     int
-	MaxActive   int
-	IdleTimeout time.Duration
-	Wait        bool
+	lock                     sync.Mutex
+	pools                    map[RedisInstance]*instancePool
+	MaxIdle                  int
+	MaxActive                int
+	IdleTimeout              time.Duration
+	Wait                     bool
+	ConnectTimeout           time.Duration
+	ReadTimeout              time.Duration
+	WriteTimeout             time.Duration
+	Username                 string
+	Password                 string
+	TLSConfig                *tls.Config
+	HealthCheckPeriod        time.Duration
+	CircuitBreakerThreshold  int
+	CircuitBreakerBackoff    time.Duration
+	CircuitBreakerMaxBackoff time.Duration
+}
+
+// PoolStats represents the connections of a Pool to a Redis instance.
+type PoolStats struct {
+	Active int
+	Idle   int
+}
+
+// An instancePool is the pool of connections to a single Redis instance.
+//
+// redigo only counts the active connections, which include the idle ones, so
+// the connections in use are counted separately.
+type instancePool struct {
+	pool    *redis.Pool
+	breaker *CircuitBreaker
+	lock    sync.Mutex
+	inUse   int
+}
+
+func (p *instancePool) add(delta int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.inUse += delta
+}
+
+func (p *instancePool) stats() PoolStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	active := p.pool.ActiveCount()
+	idle := active - p.inUse
+
+	if idle < 0 {
+		idle = 0
+	}
+
+	return PoolStats{Active: active, Idle: idle}
+}
+
+// A pooledConn is a connection that is accounted as in use until it is
+// closed.
+type pooledConn struct {
+	redis.Conn
+	pool   *instancePool
+	closed bool
+}
+
+func (c *pooledConn) Close() error {
+	if !c.closed {
+		c.closed = true
+		c.pool.add(-1)
+
+		// redigo connections report an error once they are broken.
+		if c.Conn.Err() != nil {
+			c.pool.breaker.Failure()
+		} else {
+			c.pool.breaker.Success()
+		}
+	}
+
+	return c.Conn.Close()
 }
 
 // Get a connection to the specified Redis instance.
@@ -24,29 +120,204 @@ func (p *Pool) Get(redisInstance RedisInstance) redis.Conn {
 	p.lock.Lock()
 
 	if p.pools == nil {
-		p.pools = make(map[RedisInstance]*redis.Pool)
+		p.pools = make(map[RedisInstance]*instancePool)
 	}
 
 	pool := p.pools[redisInstance]
 
 	if pool == nil {
-		pool = &redis.Pool{
-			Dial: func() (redis.Conn, error) {
-				return redis.Dial("tcp", redisInstance.String())
+		breaker := &CircuitBreaker{
+			Threshold:  p.CircuitBreakerThreshold,
+			Backoff:    p.CircuitBreakerBackoff,
+			MaxBackoff: p.CircuitBreakerMaxBackoff,
+		}
+
+		pool = &instancePool{
+			breaker: breaker,
+			pool: &redis.Pool{
+				Dial: func() (redis.Conn, error) {
+					conn, err := p.dial(redisInstance)
+
+					if err != nil {
+						breaker.Failure()
+					}
+
+					return conn, err
+				},
+				TestOnBorrow: p.testOnBorrow,
+				MaxIdle:      p.MaxIdle,
+				MaxActive:    p.MaxActive,
+				IdleTimeout:  p.IdleTimeout,
+				Wait:         p.Wait,
 			},
-			MaxIdle:     p.MaxIdle,
-			MaxActive:   p.MaxActive,
-			IdleTimeout: p.IdleTimeout,
-			Wait:        p.Wait,
 		}
 		p.pools[redisInstance] = pool
 	}
 
 	p.lock.Unlock()
 
-	return pool.Get()
+	if until, open := pool.breaker.OpenUntil(); open {
+		return errorConn{&CircuitOpenError{RedisInstance: redisInstance, Until: until}}
+	}
+
+	conn := pool.pool.Get()
+
+	// Connections that failed to be established are not active.
+	if conn.Err() != nil {
+		return conn
+	}
+
+	pool.add(1)
+
+	return &pooledConn{Conn: conn, pool: pool}
+}
+
+func (p *Pool) testOnBorrow(conn redis.Conn, t time.Time) error {
+	if p.HealthCheckPeriod == 0 || time.Since(t) < p.HealthCheckPeriod {
+		return nil
+	}
+
+	_, err := conn.Do("PING")
+
+	return err
+}
+
+// Retain closes the connections to all the Redis instances except the
+// specified ones.
+//
+// It is meant to be called whenever the managed instances change, so that
+// connections to instances that left the cluster don't leak.
+func (p *Pool) Retain(redisInstances []RedisInstance) error {
+	retained := make(map[RedisInstance]bool, len(redisInstances))
+
+	for _, redisInstance := range redisInstances {
+		retained[redisInstance] = true
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	errors := make(closeError, 0)
+
+	for redisInstance, pool := range p.pools {
+		if retained[redisInstance] {
+			continue
+		}
+
+		if err := pool.pool.Close(); err != nil {
+			errors = append(errors, fmt.Errorf("closing pool for %s: %s", redisInstance, err))
+		}
+
+		delete(p.pools, redisInstance)
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+
+	return nil
+}
+
+// OpenCircuits returns the Redis instances whose circuit is currently open,
+// and until when.
+func (p *Pool) OpenCircuits() map[RedisInstance]time.Time {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	openCircuits := make(map[RedisInstance]time.Time)
+
+	for redisInstance, pool := range p.pools {
+		if until, open := pool.breaker.OpenUntil(); open {
+			openCircuits[redisInstance] = until
+		}
+	}
+
+	return openCircuits
+}
+
+// Stats returns the statistics of the connections to every Redis instance.
+func (p *Pool) Stats() map[RedisInstance]PoolStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stats := make(map[RedisInstance]PoolStats, len(p.pools))
+
+	for redisInstance, pool := range p.pools {
+		stats[redisInstance] = pool.stats()
+	}
+
+	return stats
 }
 
+func (p *Pool) dial(redisInstance RedisInstance) (redis.Conn, error) {
+	options := []redis.DialOption{
+		redis.DialConnectTimeout(p.ConnectTimeout),
+		redis.DialReadTimeout(p.ReadTimeout),
+		redis.DialWriteTimeout(p.WriteTimeout),
+	}
+
+	if p.TLSConfig != nil {
+		dialer := &net.Dialer{Timeout: p.ConnectTimeout}
+		config := p.TLSConfig.Clone()
+
+		if config.ServerName == "" {
+			config.ServerName = redisInstance.Hostname
+		}
+
+		options = append(options, redis.DialNetDial(func(network, address string) (net.Conn, error) {
+			return tls.DialWithDialer(dialer, network, address, config)
+		}))
+	}
+
+	conn, err := redis.Dial("tcp", redisInstance.String(), options...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Password != "" {
+		args := []interface{}{p.Password}
+
+		if p.Username != "" {
+			args = []interface{}{p.Username, p.Password}
+		}
+
+		if _, err = conn.Do("AUTH", args...); err != nil {
+			conn.Close()
+
+			return nil, fmt.Errorf("authenticating to %s: %s", redisInstance, err)
+		}
+	}
+
+	return conn, nil
+}
+
+// migrateAuthArgs returns the MIGRATE arguments that authenticate to the
+// destination instance with the pool credentials.
+func (p *Pool) migrateAuthArgs() []interface{} {
+	if p.Password == "" {
+		return nil
+	}
+
+	if p.Username != "" {
+		return []interface{}{"AUTH2", p.Username, p.Password}
+	}
+
+	return []interface{}{"AUTH", p.Password}
+}
+
+// An errorConn is a connection that fails all its commands.
+type errorConn struct {
+	err error
+}
+
+func (c errorConn) Do(string, ...interface{}) (interface{}, error) { return nil, c.err }
+func (c errorConn) Send(string, ...interface{}) error              { return c.err }
+func (c errorConn) Flush() error                                   { return c.err }
+func (c errorConn) Receive() (interface{}, error)                  { return nil, c.err }
+func (c errorConn) Err() error                                     { return c.err }
+func (c errorConn) Close() error                                   { return nil }
+
 type closeError []error
 
 func (e closeError) Error() string {
@@ -70,7 +341,7 @@ func (p *Pool) Close() error {
 	errors := make(closeError, 0)
 
 	for redisInstance, pool := range p.pools {
-		err := pool.Close()
+		err := pool.pool.Close()
 
 		if err != nil {
 			errors = append(errors, fmt.Errorf("closing pool for %s: %s", redisInstance, err))
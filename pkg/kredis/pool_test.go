@@ -0,0 +1,342 @@
+package kredis
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveFakeRedis accepts a single connection and answers every command with
+// the specified reply, sending the received commands on the returned
+// channel.
+func serveFakeRedis(t *testing.T, reply string) (RedisInstance, <-chan []string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	return serveFakeRedisOn(listener, reply)
+}
+
+func serveFakeRedisOn(listener net.Listener, reply string) (RedisInstance, <-chan []string) {
+	commands := make(chan []string, 10)
+
+	go func() {
+		defer listener.Close()
+		defer close(commands)
+
+		conn, err := listener.Accept()
+
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+
+		for {
+			command, err := readFakeRedisCommand(reader)
+
+			if err != nil {
+				return
+			}
+
+			commands <- command
+			conn.Write([]byte(reply + "\r\n"))
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	return RedisInstance{Hostname: host, Port: port}, commands
+}
+
+// serveScriptedRedis accepts connections until the returned function is
+// called, and answers every command with the reply returned by handle, which
+// must be safe for concurrent use.
+func serveScriptedRedis(t *testing.T, handle func(command []string) string) (RedisInstance, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				reader := bufio.NewReader(conn)
+
+				for {
+					command, err := readFakeRedisCommand(reader)
+
+					if err != nil {
+						return
+					}
+
+					conn.Write([]byte(handle(command) + "\r\n"))
+				}
+			}()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	return RedisInstance{Hostname: host, Port: port}, func() { listener.Close() }
+}
+
+func readFakeRedisCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+
+	if err != nil {
+		return nil, err
+	}
+
+	count, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	command := make([]string, count)
+
+	for i := range command {
+		reader.ReadString('\n')
+		arg, _ := reader.ReadString('\n')
+		command[i] = strings.TrimSpace(arg)
+	}
+
+	return command, nil
+}
+
+// bulkString formats a bulk string reply.
+func bulkString(s string) string {
+	return fmt.Sprintf("$%d\r\n%s", len(s), s)
+}
+
+func TestPoolAuthentication(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Username string
+		Password string
+		Expected []string
+	}{
+		{
+			Name:     "password",
+			Password: "secret",
+			Expected: []string{"AUTH", "secret"},
+		},
+		{
+			Name:     "acl",
+			Username: "kredis",
+			Password: "secret",
+			Expected: []string{"AUTH", "kredis", "secret"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			redisInstance, commands := serveFakeRedis(t, "+OK")
+			pool := &Pool{Username: testCase.Username, Password: testCase.Password}
+			defer pool.Close()
+
+			conn, err := pool.dial(redisInstance)
+
+			if err != nil {
+				t.Fatalf("expected no error but got: %s", err)
+			}
+
+			conn.Close()
+
+			if command := <-commands; !reflect.DeepEqual(command, testCase.Expected) {
+				t.Errorf("expected: %v, got: %v", testCase.Expected, command)
+			}
+		})
+	}
+}
+
+func TestPoolAuthenticationFailure(t *testing.T) {
+	redisInstance, _ := serveFakeRedis(t, "-WRONGPASS invalid username-password pair")
+	pool := &Pool{Password: "wrong"}
+	defer pool.Close()
+
+	if _, err := pool.dial(redisInstance); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestPoolMigrateAuthArgs(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Pool     *Pool
+		Expected []interface{}
+	}{
+		{
+			Name: "none",
+			Pool: &Pool{},
+		},
+		{
+			Name:     "password",
+			Pool:     &Pool{Password: "secret"},
+			Expected: []interface{}{"AUTH", "secret"},
+		},
+		{
+			Name:     "acl",
+			Pool:     &Pool{Username: "kredis", Password: "secret"},
+			Expected: []interface{}{"AUTH2", "kredis", "secret"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			args := testCase.Pool.migrateAuthArgs()
+
+			if !reflect.DeepEqual(args, testCase.Expected) {
+				t.Errorf("expected: %v, got: %v", testCase.Expected, args)
+			}
+		})
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	redisInstance, commands := serveFakeRedis(t, "+PONG")
+	pool := &Pool{MaxIdle: 1, HealthCheckPeriod: time.Hour}
+	defer pool.Close()
+
+	conn := pool.Get(redisInstance)
+
+	if _, err := conn.Do("PING"); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	<-commands
+
+	expected := map[RedisInstance]PoolStats{redisInstance: {Active: 1, Idle: 0}}
+
+	if stats := pool.Stats(); !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected: %v, got: %v", expected, stats)
+	}
+
+	conn.Close()
+
+	// Closing a connection twice must not account it twice.
+	conn.Close()
+
+	expected = map[RedisInstance]PoolStats{redisInstance: {Active: 1, Idle: 1}}
+
+	if stats := pool.Stats(); !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected: %v, got: %v", expected, stats)
+	}
+
+	if err := pool.Retain([]RedisInstance{redisInstance}); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	if stats := pool.Stats(); !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected: %v, got: %v", expected, stats)
+	}
+
+	if err := pool.Retain(nil); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	expected = map[RedisInstance]PoolStats{}
+
+	if stats := pool.Stats(); !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected: %v, got: %v", expected, stats)
+	}
+}
+
+func TestPoolTestOnBorrow(t *testing.T) {
+	redisInstance, commands := serveFakeRedis(t, "+PONG")
+	pool := &Pool{HealthCheckPeriod: time.Hour}
+	defer pool.Close()
+
+	conn, err := pool.dial(redisInstance)
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	defer conn.Close()
+
+	if err = pool.testOnBorrow(conn, time.Now()); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	if err = pool.testOnBorrow(conn, time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	if command := <-commands; !reflect.DeepEqual(command, []string{"PING"}) {
+		t.Errorf("expected: %v, got: %v", []string{"PING"}, command)
+	}
+
+	select {
+	case command := <-commands:
+		t.Errorf("expected no other command, got: %v", command)
+	default:
+	}
+
+	// A zero period disables the checks.
+	pool.HealthCheckPeriod = 0
+
+	if err = pool.testOnBorrow(conn, time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	select {
+	case command := <-commands:
+		t.Errorf("expected no other command, got: %v", command)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
+
+func TestPoolCircuitBreaker(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	// Nothing listens on that address anymore, so connections are refused.
+	listener.Close()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	redisInstance := RedisInstance{Hostname: host, Port: port}
+	pool := &Pool{
+		CircuitBreakerThreshold: 2,
+		CircuitBreakerBackoff:   time.Hour,
+	}
+	defer pool.Close()
+
+	for i := 0; i < 2; i++ {
+		conn := pool.Get(redisInstance)
+
+		if _, ok := conn.Err().(*CircuitOpenError); ok || conn.Err() == nil {
+			t.Errorf("expected a connection error but got: %v", conn.Err())
+		}
+
+		conn.Close()
+	}
+
+	if openCircuits := pool.OpenCircuits(); len(openCircuits) != 1 {
+		t.Errorf("expected one open circuit but got: %v", openCircuits)
+	}
+
+	conn := pool.Get(redisInstance)
+	defer conn.Close()
+
+	if _, ok := conn.Err().(*CircuitOpenError); !ok {
+		t.Errorf("expected a circuit open error but got: %v", conn.Err())
+	}
+}
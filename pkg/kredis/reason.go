@@ -0,0 +1,77 @@
+package kredis
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// ReasonCode identifies why an operation was planned.
+type ReasonCode string
+
+const (
+	// ReasonMissingConnection indicates that a node doesn't know about
+	// another node it should be connected to.
+	ReasonMissingConnection ReasonCode = "missing-connection"
+	// ReasonUnknownNode indicates that a node knows about a node that is not
+	// part of any master group.
+	ReasonUnknownNode ReasonCode = "unknown-node"
+	// ReasonMultipleMasters indicates that a master group has more than one
+	// master.
+	ReasonMultipleMasters ReasonCode = "multiple-masters"
+	// ReasonUnknownMaster indicates that a node replicates a master that is
+	// not part of any master group.
+	ReasonUnknownMaster ReasonCode = "unknown-master"
+	// ReasonUnassignedSlots indicates that managed slots are not owned by any
+	// master.
+	ReasonUnassignedSlots ReasonCode = "unassigned-slots"
+	// ReasonMisassignedSlot indicates that a slot is owned by another master
+	// than the one it is assigned to.
+	ReasonMisassignedSlot ReasonCode = "misassigned-slot"
+	// ReasonRemovedOwner indicates that a slot is owned by a master that is
+	// not part of any master group, and must be drained before it can be
+	// forgotten.
+	ReasonRemovedOwner ReasonCode = "removed-owner"
+	// ReasonOpenMigration indicates that a slot migration was left
+	// half-finished and can be resumed.
+	ReasonOpenMigration ReasonCode = "open-migration"
+	// ReasonDanglingMigration indicates that a node was left in a migrating
+	// or importing state for a slot migration that can't be resumed.
+	ReasonDanglingMigration ReasonCode = "dangling-migration"
+	// ReasonFailedMaster indicates that a majority of the masters flag a
+	// master as failed and that none of its replicas took over.
+	ReasonFailedMaster ReasonCode = "failed-master"
+)
+
+// A Reason explains why an operation was planned.
+//
+// Evidence holds the facts, observed in the cluster, that led to the
+// operation.
+type Reason struct {
+	Code     ReasonCode
+	Evidence map[string]string
+}
+
+func (r Reason) String() string {
+	var buffer bytes.Buffer
+
+	buffer.WriteString(string(r.Code))
+
+	keys := make([]string, 0, len(r.Evidence))
+
+	for key := range r.Evidence {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for i, key := range keys {
+		if i == 0 {
+			buffer.WriteString(":")
+		}
+
+		fmt.Fprintf(&buffer, " %s=%s", key, r.Evidence[key])
+	}
+
+	return buffer.String()
+}
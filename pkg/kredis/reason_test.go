@@ -0,0 +1,36 @@
+package kredis
+
+import "testing"
+
+func TestReasonString(t *testing.T) {
+	testCases := []struct {
+		Reason   Reason
+		Expected string
+	}{
+		{
+			Reason:   Reason{Code: ReasonUnassignedSlots},
+			Expected: "unassigned-slots",
+		},
+		{
+			Reason: Reason{
+				Code: ReasonMisassignedSlot,
+				Evidence: map[string]string{
+					"slot":     "0",
+					"owner":    "b",
+					"assignee": "a",
+				},
+			},
+			Expected: "misassigned-slot: assignee=a owner=b slot=0",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Expected, func(t *testing.T) {
+			value := testCase.Reason.String()
+
+			if value != testCase.Expected {
+				t.Errorf("expected: `%s`, got: `%s`", testCase.Expected, value)
+			}
+		})
+	}
+}
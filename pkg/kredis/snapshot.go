@@ -0,0 +1,65 @@
+package kredis
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A clusterNodesResult holds the result of fetching the cluster nodes of a
+// Redis instance.
+type clusterNodesResult struct {
+	Nodes ClusterNodes
+	Err   error
+}
+
+// collectClusterNodes fetches the cluster nodes of every specified Redis
+// instance in parallel, with at most concurrency simultaneous fetches, each
+// bounded by the specified timeout.
+//
+// The results are returned in the same order as the Redis instances, so that
+// callers can process them deterministically.
+func collectClusterNodes(ctx context.Context, redisInstances []RedisInstance, concurrency int, timeout time.Duration, fetch func(context.Context, RedisInstance) (ClusterNodes, error)) []clusterNodesResult {
+	results := make([]clusterNodesResult, len(redisInstances))
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	if concurrency > len(redisInstances) {
+		concurrency = len(redisInstances)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for index := range indexes {
+				fetchCtx := ctx
+
+				if timeout > 0 {
+					var cancel func()
+					fetchCtx, cancel = context.WithTimeout(ctx, timeout)
+					results[index].Nodes, results[index].Err = fetch(fetchCtx, redisInstances[index])
+					cancel()
+				} else {
+					results[index].Nodes, results[index].Err = fetch(fetchCtx, redisInstances[index])
+				}
+			}
+		}()
+	}
+
+	for i := range redisInstances {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,79 @@
+package kredis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCollectClusterNodes(t *testing.T) {
+	redisInstances := []RedisInstance{riA, riB, riC}
+	nodes := map[RedisInstance]ClusterNodes{
+		riA: nodesA,
+		riB: nodesB,
+	}
+	errC := errors.New("c is down")
+
+	var lock sync.Mutex
+	running, maxRunning := 0, 0
+
+	results := collectClusterNodes(context.Background(), redisInstances, 2, time.Second, func(ctx context.Context, redisInstance RedisInstance) (ClusterNodes, error) {
+		lock.Lock()
+		running++
+
+		if running > maxRunning {
+			maxRunning = running
+		}
+
+		lock.Unlock()
+
+		time.Sleep(time.Millisecond * 5)
+
+		lock.Lock()
+		running--
+		lock.Unlock()
+
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("expected the context to have a deadline")
+		}
+
+		if redisInstance == riC {
+			return nil, errC
+		}
+
+		return nodes[redisInstance], nil
+	})
+
+	if len(results) != len(redisInstances) {
+		t.Fatalf("expected %d results but got %d", len(redisInstances), len(results))
+	}
+
+	if results[0].Err != nil || results[0].Nodes.String() != nodesA.String() {
+		t.Errorf("expected the nodes of a but got: %v", results[0])
+	}
+
+	if results[1].Err != nil || results[1].Nodes.String() != nodesB.String() {
+		t.Errorf("expected the nodes of b but got: %v", results[1])
+	}
+
+	if results[2].Err != errC {
+		t.Errorf("expected %s but got: %v", errC, results[2].Err)
+	}
+
+	if maxRunning > 2 {
+		t.Errorf("expected at most 2 concurrent fetches but got %d", maxRunning)
+	}
+}
+
+func TestCollectClusterNodesEmpty(t *testing.T) {
+	results := collectClusterNodes(context.Background(), nil, 4, 0, func(ctx context.Context, redisInstance RedisInstance) (ClusterNodes, error) {
+		t.Error("expected no fetch")
+		return nil, nil
+	})
+
+	if len(results) != 0 {
+		t.Errorf("expected no results but got: %v", results)
+	}
+}
@@ -0,0 +1,101 @@
+#!/bin/sh
+#
+# Captures the CLUSTER NODES output of real Redis clusters, one per major
+# version, into testdata/cluster-nodes.
+#
+# Each cluster has 3 masters and 3 replicas, and the captured node sees:
+#
+# - a slot it is migrating and a slot it is importing,
+# - a replica that stopped answering (fail? or fail),
+# - a node in handshake,
+# - hostnames, on Redis 7.0 and later.
+#
+# The noaddr flag can't be reliably induced: it is only covered by the
+# synthetic corpus.
+#
+# Requires docker. Usage: ./capture-cluster-nodes.sh [version...]
+
+set -eu
+
+cd "$(dirname "$0")"
+mkdir -p cluster-nodes
+
+if [ $# -eq 0 ]; then
+	set -- 3.2 4.0 5.0 6.2 7.0 7.2
+fi
+
+for version in "$@"; do
+	container="kredis-capture-${version}"
+	options="--cluster-enabled yes --cluster-node-timeout 2000 --daemonize yes"
+
+	case "${version}" in
+	3.* | 4.* | 5.* | 6.*) hostnames=false ;;
+	*) hostnames=true ;;
+	esac
+
+	start=""
+
+	for port in 7000 7001 7002 7003 7004 7005; do
+		extra="--cluster-config-file nodes-${port}.conf"
+
+		if ${hostnames}; then
+			extra="${extra} --cluster-announce-hostname redis-${port}.example.com"
+		fi
+
+		start="${start} redis-server --port ${port} ${options} ${extra};"
+	done
+
+	docker rm -f "${container}" >/dev/null 2>&1 || true
+	docker run -d --name "${container}" "redis:${version}" sh -c "${start} sleep 3600" >/dev/null
+
+	cli() {
+		port=$1
+		shift
+		docker exec "${container}" redis-cli -p "${port}" "$@"
+	}
+
+	id() {
+		cli "$1" CLUSTER MYID 2>/dev/null || cli "$1" CLUSTER NODES | awk '/myself/ { print $1 }'
+	}
+
+	for port in 7000 7001 7002 7003 7004 7005; do
+		until cli "${port}" PING >/dev/null 2>&1; do sleep 0.1; done
+	done
+
+	for port in 7001 7002 7003 7004 7005; do
+		cli "${port}" CLUSTER MEET 127.0.0.1 7000 >/dev/null
+	done
+
+	cli 7000 CLUSTER ADDSLOTS $(seq 0 5460) >/dev/null
+	cli 7001 CLUSTER ADDSLOTS $(seq 5461 10922) >/dev/null
+	cli 7002 CLUSTER ADDSLOTS $(seq 10923 16383) >/dev/null
+
+	until [ "$(cli 7000 CLUSTER NODES | wc -l)" -eq 6 ]; do sleep 0.1; done
+	sleep 2
+
+	cli 7003 CLUSTER REPLICATE "$(id 7000)" >/dev/null
+	cli 7004 CLUSTER REPLICATE "$(id 7001)" >/dev/null
+	cli 7005 CLUSTER REPLICATE "$(id 7002)" >/dev/null
+
+	until cli 7000 CLUSTER INFO | grep -q cluster_state:ok; do sleep 0.1; done
+
+	cli 7000 CLUSTER SETSLOT 100 MIGRATING "$(id 7001)" >/dev/null
+	cli 7001 CLUSTER SETSLOT 100 IMPORTING "$(id 7000)" >/dev/null
+	cli 7001 CLUSTER SETSLOT 6000 MIGRATING "$(id 7000)" >/dev/null
+	cli 7000 CLUSTER SETSLOT 6000 IMPORTING "$(id 7001)" >/dev/null
+
+	pid=$(cli 7005 INFO server | awk -F: '/^process_id/ { print $2 }' | tr -d '\r')
+	docker exec "${container}" sh -c "kill -STOP ${pid}"
+	sleep 3
+
+	# Nothing listens on that port, so the node stays in handshake until
+	# the node timeout.
+	cli 7000 CLUSTER MEET 127.0.0.1 7999 >/dev/null
+
+	{
+		cli 7000 INFO server | grep '^redis_version:' | tr -d '\r' | sed 's/^/# /'
+		cli 7000 CLUSTER NODES
+	} >"cluster-nodes/redis-${version}.txt"
+
+	docker rm -f "${container}" >/dev/null
+done
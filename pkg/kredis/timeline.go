@@ -0,0 +1,86 @@
+package kredis
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Timeline tracks for how long conditions have been continuously observed
+// across sync cycles.
+//
+// Conditions are identified by a key. Every cycle, the planner observes the
+// conditions it sees and the manager commits the cycle, which forgets the
+// conditions that were not observed during it.
+type Timeline struct {
+	lock      sync.Mutex
+	firstSeen map[string]time.Time
+	observed  map[string]bool
+	timeFunc  func() time.Time
+}
+
+func (t *Timeline) init() {
+	if t.timeFunc == nil {
+		t.timeFunc = func() time.Time { return time.Now().UTC() }
+	}
+
+	if t.firstSeen == nil {
+		t.firstSeen = make(map[string]time.Time)
+		t.observed = make(map[string]bool)
+	}
+}
+
+// Observe records that the condition identified by the specified key holds,
+// and returns for how long it has been continuously observed.
+func (t *Timeline) Observe(key string) time.Duration {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.init()
+
+	now := t.timeFunc()
+	firstSeen, ok := t.firstSeen[key]
+
+	if !ok {
+		firstSeen = now
+		t.firstSeen[key] = now
+	}
+
+	t.observed[key] = true
+
+	return now.Sub(firstSeen)
+}
+
+// Keep records that the conditions whose key starts with any of the specified
+// prefixes still hold, without observing new ones.
+func (t *Timeline) Keep(prefixes ...string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.init()
+
+	for key := range t.firstSeen {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				t.observed[key] = true
+			}
+		}
+	}
+}
+
+// Commit ends a cycle, forgetting the conditions that were not observed
+// since the previous commit.
+func (t *Timeline) Commit() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.init()
+
+	for key := range t.firstSeen {
+		if !t.observed[key] {
+			delete(t.firstSeen, key)
+		}
+	}
+
+	t.observed = make(map[string]bool)
+}
@@ -0,0 +1,55 @@
+package kredis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeline(t *testing.T) {
+	now := time.Now().UTC()
+	timeline := &Timeline{timeFunc: func() time.Time { return now }}
+
+	assertObserve := func(key string, expected time.Duration) {
+		t.Helper()
+
+		if elapsed := timeline.Observe(key); elapsed != expected {
+			t.Errorf("expected %s to be observed for %s but got: %s", key, expected, elapsed)
+		}
+	}
+
+	assertObserve("a", 0)
+	assertObserve("b", 0)
+	timeline.Commit()
+
+	now = now.Add(time.Second)
+	assertObserve("a", time.Second)
+	timeline.Commit()
+
+	// "b" was not observed during the previous cycle, so it starts over.
+	now = now.Add(time.Second)
+	assertObserve("a", time.Second*2)
+	assertObserve("b", 0)
+}
+
+func TestTimelineKeep(t *testing.T) {
+	now := time.Now().UTC()
+	timeline := &Timeline{timeFunc: func() time.Time { return now }}
+
+	timeline.Observe("drain:a")
+	timeline.Observe("forget:b")
+	timeline.Commit()
+
+	now = now.Add(time.Second)
+	timeline.Keep("drain:")
+	timeline.Commit()
+
+	now = now.Add(time.Second)
+
+	if elapsed := timeline.Observe("drain:a"); elapsed != time.Second*2 {
+		t.Errorf("expected the kept condition to be observed for 2s but got: %s", elapsed)
+	}
+
+	if elapsed := timeline.Observe("forget:b"); elapsed != 0 {
+		t.Errorf("expected the other condition to start over but got: %s", elapsed)
+	}
+}
@@ -0,0 +1,58 @@
+package kredis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// NewTLSConfig creates a TLS configuration for connecting to Redis instances.
+//
+// If caFile is empty, the system certificate pool is used to verify the
+// instances certificates. A client certificate is only loaded if both
+// certFile and keyFile are specified. If serverName is empty, the hostname of
+// each instance is used.
+func NewTLSConfig(caFile, certFile, keyFile, serverName string, skipVerify bool) (config *tls.Config, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("creating TLS configuration: %s", err)
+		}
+	}()
+
+	config = &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: skipVerify,
+	}
+
+	if caFile != "" {
+		var ca []byte
+
+		if ca, err = ioutil.ReadFile(caFile); err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in %s", caFile)
+		}
+	}
+
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("a client certificate and its key must be specified together")
+	}
+
+	if certFile != "" {
+		var certificate tls.Certificate
+
+		if certificate, err = tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+			return nil, err
+		}
+
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,180 @@
+package kredis
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedCertificate writes a self-signed certificate for localhost
+// and its key in the specified directory.
+func writeSelfSignedCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	certFile = filepath.Join(dir, "tls.crt")
+	keyFile = filepath.Join(dir, "tls.key")
+
+	if err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	if err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	return
+}
+
+func TestNewTLSConfigFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kredis")
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeSelfSignedCertificate(t, dir)
+
+	testCases := []struct {
+		Name     string
+		CAFile   string
+		CertFile string
+		KeyFile  string
+	}{
+		{Name: "missing-ca", CAFile: filepath.Join(dir, "missing")},
+		{Name: "invalid-ca", CAFile: keyFile},
+		{Name: "cert-without-key", CertFile: certFile},
+		{Name: "key-without-cert", KeyFile: keyFile},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			if _, err := NewTLSConfig(testCase.CAFile, testCase.CertFile, testCase.KeyFile, "", false); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestPoolTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kredis")
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeSelfSignedCertificate(t, dir)
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{certificate}})
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	redisInstance, commands := serveFakeRedisOn(listener, "+PONG")
+
+	// The instance hostname is an IP address that doesn't match the
+	// certificate, so the server name must be used for verification.
+	config, err := NewTLSConfig(certFile, certFile, keyFile, "localhost", false)
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	pool := &Pool{TLSConfig: config}
+	defer pool.Close()
+
+	conn, err := pool.dial(redisInstance)
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	defer conn.Close()
+
+	if _, err = conn.Do("PING"); err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	if command := <-commands; !reflect.DeepEqual(command, []string{"PING"}) {
+		t.Errorf("expected: %v, got: %v", []string{"PING"}, command)
+	}
+}
+
+func TestPoolTLSUnknownAuthority(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kredis")
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeSelfSignedCertificate(t, dir)
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{certificate}})
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	redisInstance, _ := serveFakeRedisOn(listener, "+PONG")
+	pool := &Pool{TLSConfig: &tls.Config{ServerName: "localhost"}}
+	defer pool.Close()
+
+	if _, err = pool.dial(redisInstance); err == nil {
+		t.Error("expected an error")
+	}
+}
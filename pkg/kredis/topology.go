@@ -0,0 +1,441 @@
+package kredis
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// TopologyCommand represents the Redis command used to fetch the cluster
+// topology from a node.
+//
+// `CLUSTER NODES` is always queried as well: the other commands don't report
+// the nodes without slots, which may include the node itself, nor the node
+// flags like `handshake` or `fail`, nor the slots in the middle of a
+// migration.
+type TopologyCommand string
+
+const (
+	// TopologyAuto uses `CLUSTER NODES`, enriched with the replication
+	// offsets and health reported by `CLUSTER SHARDS` if the node supports
+	// it.
+	TopologyAuto TopologyCommand = "auto"
+	// TopologyNodes uses `CLUSTER NODES`.
+	TopologyNodes TopologyCommand = "nodes"
+	// TopologyShards takes the slots, roles, replication offsets and health
+	// of the nodes from `CLUSTER SHARDS`, available since Redis 7.0.
+	TopologyShards TopologyCommand = "shards"
+	// TopologySlots takes the slots and roles of the nodes that serve slots
+	// from `CLUSTER SLOTS`.
+	TopologySlots TopologyCommand = "slots"
+)
+
+// ParseTopologyCommand parses a topology command.
+func ParseTopologyCommand(s string) (TopologyCommand, error) {
+	switch command := TopologyCommand(s); command {
+	case TopologyAuto, TopologyNodes, TopologyShards, TopologySlots:
+		return command, nil
+	default:
+		return "", fmt.Errorf("unknown topology command \"%s\"", s)
+	}
+}
+
+// ClusterNodeHealth represents the health of a cluster node, as reported by
+// `CLUSTER SHARDS`.
+type ClusterNodeHealth string
+
+const (
+	// HealthOnline means the node is healthy.
+	HealthOnline ClusterNodeHealth = "online"
+	// HealthFailed means the node is failed.
+	HealthFailed ClusterNodeHealth = "failed"
+	// HealthLoading means the node is loading its data set.
+	HealthLoading ClusterNodeHealth = "loading"
+)
+
+// parseReplyMap parses a reply made of alternating keys and values.
+func parseReplyMap(reply interface{}) (map[string]interface{}, error) {
+	values, err := redis.Values(reply, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values)%2 != 0 {
+		return nil, fmt.Errorf("expected key-value pairs but got %d values", len(values))
+	}
+
+	result := make(map[string]interface{}, len(values)/2)
+
+	for i := 0; i < len(values); i += 2 {
+		key, err := redis.String(values[i], nil)
+
+		if err != nil {
+			return nil, fmt.Errorf("parsing key %d: %s", i/2, err)
+		}
+
+		result[key] = values[i+1]
+	}
+
+	return result, nil
+}
+
+// parseSlotRanges parses a flat list of slot ranges bounds.
+func parseSlotRanges(reply interface{}) (slots HashSlots, err error) {
+	bounds, err := redis.Ints(reply, nil)
+
+	if err != nil {
+		return
+	}
+
+	if len(bounds)%2 != 0 {
+		err = fmt.Errorf("expected slot ranges but got %d bounds", len(bounds))
+		return
+	}
+
+	slots = HashSlots{}
+
+	for i := 0; i < len(bounds); i += 2 {
+		if bounds[i] < 0 || bounds[i] > bounds[i+1] || bounds[i+1] >= SlotsCount {
+			err = fmt.Errorf("invalid slot range %d-%d", bounds[i], bounds[i+1])
+			return
+		}
+
+		slots = append(slots, NewHashSlotsFromRange(bounds[i], bounds[i+1], 1)...)
+	}
+
+	return
+}
+
+func parseShardNode(reply interface{}) (node ClusterNode, err error) {
+	fields, err := parseReplyMap(reply)
+
+	if err != nil {
+		return
+	}
+
+	id, err := redis.String(fields["id"], nil)
+
+	if err != nil || id == "" {
+		err = fmt.Errorf("missing node ID")
+		return
+	}
+
+	node.ID = ClusterNodeID(id)
+	node.Flags = ClusterNodeFlags{}
+	node.Slots = HashSlots{}
+	node.LinkState = LinkStateConnected
+
+	if ip, ok := fields["ip"]; ok {
+		s, _ := redis.String(ip, nil)
+		node.Address.IP = net.ParseIP(s)
+	}
+
+	if hostname, ok := fields["hostname"]; ok {
+		node.Address.Hostname, _ = redis.String(hostname, nil)
+	}
+
+	if port, ok := fields["port"]; ok {
+		var p int
+
+		if p, err = redis.Int(port, nil); err != nil {
+			err = fmt.Errorf("parsing port of %s: %s", id, err)
+			return
+		}
+
+		node.Address.Port = strconv.Itoa(p)
+	}
+
+	if tlsPort, ok := fields["tls-port"]; ok {
+		var p int
+
+		if p, err = redis.Int(tlsPort, nil); err != nil {
+			err = fmt.Errorf("parsing TLS port of %s: %s", id, err)
+			return
+		}
+
+		node.Address.Aux = map[string]string{"tls-port": strconv.Itoa(p)}
+
+		if node.Address.Port == "" {
+			node.Address.Port = strconv.Itoa(p)
+		}
+	}
+
+	role, _ := redis.String(fields["role"], nil)
+
+	switch role {
+	case "master":
+		node.Flags[FlagMaster] = true
+	case "replica", "slave":
+		node.Flags[FlagSlave] = true
+	default:
+		err = fmt.Errorf("unknown role \"%s\" for %s", role, id)
+		return
+	}
+
+	if offset, ok := fields["replication-offset"]; ok {
+		if node.ReplicationOffset, err = redis.Int64(offset, nil); err != nil {
+			err = fmt.Errorf("parsing replication offset of %s: %s", id, err)
+			return
+		}
+	}
+
+	health, _ := redis.String(fields["health"], nil)
+	node.Health = ClusterNodeHealth(health)
+
+	if node.Health == HealthFailed {
+		node.Flags[FlagFail] = true
+		node.LinkState = LinkStateDisconnected
+	}
+
+	return
+}
+
+// ParseClusterShards parses the reply of the `CLUSTER SHARDS` Redis command.
+//
+// The reply doesn't tell which node returned it, so no node is flagged
+// `myself`.
+func ParseClusterShards(reply interface{}) (nodes ClusterNodes, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("parsing cluster shards: %s", err)
+		}
+	}()
+
+	shards, err := redis.Values(reply, nil)
+
+	if err != nil {
+		return
+	}
+
+	nodes = ClusterNodes{}
+
+	for i, shard := range shards {
+		var fields map[string]interface{}
+		var slots HashSlots
+		var shardNodes []interface{}
+
+		if fields, err = parseReplyMap(shard); err != nil {
+			err = fmt.Errorf("shard %d: %s", i, err)
+			return
+		}
+
+		if slots, err = parseSlotRanges(fields["slots"]); err != nil {
+			err = fmt.Errorf("shard %d: %s", i, err)
+			return
+		}
+
+		if shardNodes, err = redis.Values(fields["nodes"], nil); err != nil {
+			err = fmt.Errorf("shard %d: %s", i, err)
+			return
+		}
+
+		var masterID ClusterNodeID
+		shardStart := len(nodes)
+
+		for j, shardNode := range shardNodes {
+			var node ClusterNode
+
+			if node, err = parseShardNode(shardNode); err != nil {
+				err = fmt.Errorf("shard %d: node %d: %s", i, j, err)
+				return
+			}
+
+			if node.Flags[FlagMaster] {
+				node.Slots = slots
+				masterID = node.ID
+			}
+
+			nodes = append(nodes, node)
+		}
+
+		for j := shardStart; j < len(nodes); j++ {
+			if nodes[j].Flags[FlagSlave] {
+				nodes[j].MasterID = masterID
+			}
+		}
+	}
+
+	return
+}
+
+// ParseClusterSlots parses the reply of the `CLUSTER SLOTS` Redis command.
+//
+// Only the nodes serving slots, and their replicas, are listed by
+// `CLUSTER SLOTS`. The reply doesn't tell which node returned it, so no node
+// is flagged `myself`.
+func ParseClusterSlots(reply interface{}) (nodes ClusterNodes, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("parsing cluster slots: %s", err)
+		}
+	}()
+
+	ranges, err := redis.Values(reply, nil)
+
+	if err != nil {
+		return
+	}
+
+	nodesByID := map[ClusterNodeID]*ClusterNode{}
+	var ids []ClusterNodeID
+
+	getNode := func(reply interface{}) (*ClusterNode, error) {
+		values, err := redis.Values(reply, nil)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(values) < 3 {
+			return nil, fmt.Errorf("expected an IP, a port and an ID but got %d values", len(values))
+		}
+
+		ip, _ := redis.String(values[0], nil)
+		port, err := redis.Int(values[1], nil)
+
+		if err != nil {
+			return nil, fmt.Errorf("parsing port: %s", err)
+		}
+
+		id, err := redis.String(values[2], nil)
+
+		if err != nil || id == "" {
+			return nil, fmt.Errorf("missing node ID")
+		}
+
+		if node, ok := nodesByID[ClusterNodeID(id)]; ok {
+			return node, nil
+		}
+
+		node := &ClusterNode{
+			ID: ClusterNodeID(id),
+			Address: ClusterNodeAddress{
+				IP:   net.ParseIP(ip),
+				Port: strconv.Itoa(port),
+			},
+			Flags:     ClusterNodeFlags{},
+			LinkState: LinkStateConnected,
+			Slots:     HashSlots{},
+		}
+
+		nodesByID[node.ID] = node
+		ids = append(ids, node.ID)
+
+		return node, nil
+	}
+
+	for i, r := range ranges {
+		var values []interface{}
+
+		if values, err = redis.Values(r, nil); err != nil {
+			err = fmt.Errorf("range %d: %s", i, err)
+			return
+		}
+
+		if len(values) < 3 {
+			err = fmt.Errorf("range %d: expected slot bounds and a master but got %d values", i, len(values))
+			return
+		}
+
+		var slots HashSlots
+
+		if slots, err = parseSlotRanges(values[:2]); err != nil {
+			err = fmt.Errorf("range %d: %s", i, err)
+			return
+		}
+
+		var master *ClusterNode
+
+		if master, err = getNode(values[2]); err != nil {
+			err = fmt.Errorf("range %d: master: %s", i, err)
+			return
+		}
+
+		master.Flags[FlagMaster] = true
+		master.Slots = append(master.Slots, slots...)
+
+		for j, value := range values[3:] {
+			var replica *ClusterNode
+
+			if replica, err = getNode(value); err != nil {
+				err = fmt.Errorf("range %d: replica %d: %s", i, j, err)
+				return
+			}
+
+			replica.Flags[FlagSlave] = true
+			replica.MasterID = master.ID
+		}
+	}
+
+	nodes = make(ClusterNodes, len(ids))
+
+	for i, id := range ids {
+		nodes[i] = *nodesByID[id]
+		sort.Ints(nodes[i].Slots)
+	}
+
+	return
+}
+
+// mergeClusterShards copies the replication offsets and health of the nodes
+// parsed from `CLUSTER SHARDS` into the nodes parsed from `CLUSTER NODES`.
+func mergeClusterShards(nodes ClusterNodes, shardNodes ClusterNodes) {
+	byID := make(map[ClusterNodeID]ClusterNode, len(shardNodes))
+
+	for _, node := range shardNodes {
+		byID[node.ID] = node
+	}
+
+	for i := range nodes {
+		if node, ok := byID[nodes[i].ID]; ok {
+			nodes[i].ReplicationOffset = node.ReplicationOffset
+			nodes[i].Health = node.Health
+		}
+	}
+}
+
+// mergeTopology copies the slots, roles, replication offsets and health of
+// the nodes parsed from `CLUSTER SHARDS` or `CLUSTER SLOTS` into the nodes
+// parsed from `CLUSTER NODES`.
+//
+// The nodes that are only listed by `CLUSTER NODES` are left as-is, and so
+// are the other flags and the migrations of all nodes.
+func mergeTopology(nodes ClusterNodes, topologyNodes ClusterNodes) {
+	mergeClusterShards(nodes, topologyNodes)
+
+	byID := make(map[ClusterNodeID]ClusterNode, len(topologyNodes))
+
+	for _, node := range topologyNodes {
+		byID[node.ID] = node
+	}
+
+	for i := range nodes {
+		node, ok := byID[nodes[i].ID]
+
+		if !ok {
+			continue
+		}
+
+		flags := ClusterNodeFlags{}
+
+		for flag := range nodes[i].Flags {
+			if flag != FlagMaster && flag != FlagSlave {
+				flags[flag] = true
+			}
+		}
+
+		if node.Flags[FlagMaster] {
+			flags[FlagMaster] = true
+		} else {
+			flags[FlagSlave] = true
+		}
+
+		nodes[i].Flags = flags
+		nodes[i].MasterID = node.MasterID
+		nodes[i].Slots = node.Slots
+	}
+}
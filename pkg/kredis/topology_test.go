@@ -0,0 +1,346 @@
+package kredis
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// reply builds a Redis reply from strings, integers and nested replies, the
+// way redigo returns them.
+func reply(values ...interface{}) []interface{} {
+	result := make([]interface{}, len(values))
+
+	for i, value := range values {
+		switch value := value.(type) {
+		case string:
+			result[i] = []byte(value)
+		case int:
+			result[i] = int64(value)
+		default:
+			result[i] = value
+		}
+	}
+
+	return result
+}
+
+func TestParseTopologyCommand(t *testing.T) {
+	for _, s := range []string{"auto", "nodes", "shards", "slots"} {
+		t.Run(s, func(t *testing.T) {
+			command, err := ParseTopologyCommand(s)
+
+			if err != nil {
+				t.Fatalf("expected no error but got: %s", err)
+			}
+
+			if string(command) != s {
+				t.Errorf("expected: %s, got: %s", s, command)
+			}
+		})
+	}
+
+	if _, err := ParseTopologyCommand("info"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestParseClusterShards(t *testing.T) {
+	value := reply(
+		reply(
+			"slots", reply(0, 1, 4, 4),
+			"nodes", reply(
+				reply(
+					"id", "a1",
+					"port", 6379,
+					"ip", "10.0.0.1",
+					"endpoint", "10.0.0.1",
+					"hostname", "redis-0",
+					"role", "master",
+					"replication-offset", 1024,
+					"health", "online",
+				),
+				reply(
+					"id", "a2",
+					"port", 6379,
+					"tls-port", 6380,
+					"ip", "10.0.0.2",
+					"endpoint", "10.0.0.2",
+					"role", "replica",
+					"replication-offset", 1000,
+					"health", "loading",
+				),
+			),
+		),
+		reply(
+			"slots", reply(),
+			"nodes", reply(
+				reply(
+					"id", "b1",
+					"port", 6379,
+					"ip", "10.0.0.3",
+					"endpoint", "10.0.0.3",
+					"role", "master",
+					"replication-offset", 0,
+					"health", "failed",
+				),
+			),
+		),
+	)
+
+	nodes, err := ParseClusterShards(value)
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	expected := ClusterNodes{
+		{
+			ID: "a1",
+			Address: ClusterNodeAddress{
+				IP:       net.ParseIP("10.0.0.1"),
+				Port:     "6379",
+				Hostname: "redis-0",
+			},
+			Flags:             ClusterNodeFlags{FlagMaster: true},
+			LinkState:         LinkStateConnected,
+			Slots:             HashSlots{0, 1, 4},
+			ReplicationOffset: 1024,
+			Health:            HealthOnline,
+		},
+		{
+			ID: "a2",
+			Address: ClusterNodeAddress{
+				IP:   net.ParseIP("10.0.0.2"),
+				Port: "6379",
+				Aux:  map[string]string{"tls-port": "6380"},
+			},
+			Flags:             ClusterNodeFlags{FlagSlave: true},
+			MasterID:          "a1",
+			LinkState:         LinkStateConnected,
+			Slots:             HashSlots{},
+			ReplicationOffset: 1000,
+			Health:            HealthLoading,
+		},
+		{
+			ID: "b1",
+			Address: ClusterNodeAddress{
+				IP:   net.ParseIP("10.0.0.3"),
+				Port: "6379",
+			},
+			Flags:     ClusterNodeFlags{FlagMaster: true, FlagFail: true},
+			LinkState: LinkStateDisconnected,
+			Slots:     HashSlots{},
+			Health:    HealthFailed,
+		},
+	}
+
+	if !reflect.DeepEqual(nodes, expected) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, nodes)
+	}
+}
+
+func TestParseClusterShardsFailure(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Value interface{}
+	}{
+		{
+			Name:  "not-an-array",
+			Value: []byte("shards"),
+		},
+		{
+			Name:  "odd-fields",
+			Value: reply(reply("slots")),
+		},
+		{
+			Name:  "invalid-slots",
+			Value: reply(reply("slots", reply(5, 4), "nodes", reply())),
+		},
+		{
+			Name:  "missing-id",
+			Value: reply(reply("slots", reply(), "nodes", reply(reply("role", "master")))),
+		},
+		{
+			Name:  "unknown-role",
+			Value: reply(reply("slots", reply(), "nodes", reply(reply("id", "a1", "role", "arbiter")))),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			if _, err := ParseClusterShards(testCase.Value); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseClusterSlots(t *testing.T) {
+	value := reply(
+		reply(0, 1, reply("10.0.0.1", 6379, "a1"), reply("10.0.0.2", 6379, "a2")),
+		reply(2, 2, reply("10.0.0.3", 6379, "b1", reply("hostname", "redis-2"))),
+		reply(4, 4, reply("10.0.0.1", 6379, "a1"), reply("10.0.0.2", 6379, "a2")),
+	)
+
+	nodes, err := ParseClusterSlots(value)
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	expected := ClusterNodes{
+		{
+			ID:        "a1",
+			Address:   ClusterNodeAddress{IP: net.ParseIP("10.0.0.1"), Port: "6379"},
+			Flags:     ClusterNodeFlags{FlagMaster: true},
+			LinkState: LinkStateConnected,
+			Slots:     HashSlots{0, 1, 4},
+		},
+		{
+			ID:        "a2",
+			Address:   ClusterNodeAddress{IP: net.ParseIP("10.0.0.2"), Port: "6379"},
+			Flags:     ClusterNodeFlags{FlagSlave: true},
+			MasterID:  "a1",
+			LinkState: LinkStateConnected,
+			Slots:     HashSlots{},
+		},
+		{
+			ID:        "b1",
+			Address:   ClusterNodeAddress{IP: net.ParseIP("10.0.0.3"), Port: "6379"},
+			Flags:     ClusterNodeFlags{FlagMaster: true},
+			LinkState: LinkStateConnected,
+			Slots:     HashSlots{2},
+		},
+	}
+
+	if !reflect.DeepEqual(nodes, expected) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, nodes)
+	}
+}
+
+func TestParseClusterSlotsFailure(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Value interface{}
+	}{
+		{
+			Name:  "not-an-array",
+			Value: []byte("slots"),
+		},
+		{
+			Name:  "missing-master",
+			Value: reply(reply(0, 1)),
+		},
+		{
+			Name:  "invalid-range",
+			Value: reply(reply(0, SlotsCount, reply("10.0.0.1", 6379, "a1"))),
+		},
+		{
+			Name:  "missing-id",
+			Value: reply(reply(0, 1, reply("10.0.0.1", 6379))),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			if _, err := ParseClusterSlots(testCase.Value); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestMergeClusterShards(t *testing.T) {
+	nodes := ClusterNodes{{ID: "a1"}, {ID: "a2"}}
+	shardNodes := ClusterNodes{
+		{ID: "a2", ReplicationOffset: 12, Health: HealthLoading},
+		{ID: "c1", ReplicationOffset: 42, Health: HealthOnline},
+	}
+
+	mergeClusterShards(nodes, shardNodes)
+
+	expected := ClusterNodes{{ID: "a1"}, {ID: "a2", ReplicationOffset: 12, Health: HealthLoading}}
+
+	if !reflect.DeepEqual(nodes, expected) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, nodes)
+	}
+}
+
+func TestMergeTopology(t *testing.T) {
+	// "c" just joined and has no slots, while "a" is migrating a slot to "b"
+	// and "d" is failing.
+	nodes := mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected 0-1 [1->-b]
+b 1:1@1 master - 0 0 0 connected 2
+c 1:1@1 myself,master - 0 0 0 connected
+d 1:1@1 slave,fail? a 0 0 0 connected
+`)
+	topologyNodes := ClusterNodes{
+		{ID: "a", Flags: ClusterNodeFlags{FlagMaster: true}, Slots: HashSlots{0, 1}, ReplicationOffset: 42, Health: HealthOnline},
+		{ID: "b", Flags: ClusterNodeFlags{FlagSlave: true}, MasterID: "d", Slots: HashSlots{}, Health: HealthOnline},
+		{ID: "d", Flags: ClusterNodeFlags{FlagMaster: true}, Slots: HashSlots{2}, Health: HealthOnline},
+	}
+
+	mergeTopology(nodes, topologyNodes)
+
+	expected := mustParseClusterNodes(`
+a 1:1@1 master - 0 0 0 connected 0-1 [1->-b]
+b 1:1@1 slave d 0 0 0 connected
+c 1:1@1 myself,master - 0 0 0 connected
+d 1:1@1 master,fail? - 0 0 0 connected 2
+`)
+	expected[0].ReplicationOffset = 42
+	expected[0].Health = HealthOnline
+	expected[1].Health = HealthOnline
+	expected[1].Slots = HashSlots{}
+	expected[3].Health = HealthOnline
+
+	if !reflect.DeepEqual(nodes, expected) {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, nodes)
+	}
+}
+
+func TestManagerGetClusterNodesTopologyCommand(t *testing.T) {
+	// A node that just joined serves no slots and is therefore missing from
+	// `CLUSTER SLOTS`, as is the node it is in handshake with.
+	data := "" +
+		"a 10.0.0.1:6379@16379 myself,master - 0 0 0 connected\n" +
+		"b 10.0.0.2:6379@16379 master,handshake - 0 0 0 connected\n"
+	expected, err := ParseClusterNodes(data)
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	redisInstance, stop := serveScriptedRedis(t, func(command []string) string {
+		switch strings.Join(command, " ") {
+		case "CLUSTER NODES":
+			return bulkString(data)
+		case "CLUSTER SHARDS", "CLUSTER SLOTS":
+			return "*0"
+		default:
+			return "-ERR unknown command"
+		}
+	})
+	defer stop()
+
+	for _, command := range []TopologyCommand{TopologyAuto, TopologyNodes, TopologyShards, TopologySlots} {
+		t.Run(string(command), func(t *testing.T) {
+			manager := &Manager{Pool: &Pool{}, TopologyCommand: command}
+			defer manager.Pool.Close()
+
+			nodes, err := manager.GetClusterNodes(context.Background(), redisInstance)
+
+			if err != nil {
+				t.Fatalf("expected no error but got: %s", err)
+			}
+
+			if !reflect.DeepEqual(nodes, expected) {
+				t.Errorf("expected:\n%v\ngot:\n%v", expected, nodes)
+			}
+		})
+	}
+}
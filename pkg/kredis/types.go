@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math"
 	"net"
 	"regexp"
 	"sort"
@@ -93,6 +94,36 @@ func ParseMasterGroup(s string) (MasterGroup, error) {
 	return masterGroup, nil
 }
 
+// ParseWeight parses a master group weight, in the `instance=weight` form.
+func ParseWeight(s string) (redisInstance RedisInstance, weight float64, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("parsing weight \"%s\": %s", s, err)
+		}
+	}()
+
+	i := strings.LastIndex(s, "=")
+
+	if i < 0 {
+		err = errors.New("expected instance=weight")
+		return
+	}
+
+	if redisInstance, err = ParseRedisInstance(s[:i]); err != nil {
+		return
+	}
+
+	if weight, err = strconv.ParseFloat(strings.TrimSpace(s[i+1:]), 64); err != nil {
+		return
+	}
+
+	if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+		err = errors.New("weight must be a positive number or zero")
+	}
+
+	return
+}
+
 // ClusterNodeID represents a cluster ID.
 type ClusterNodeID string
 
@@ -105,27 +136,57 @@ func (i ClusterNodeID) String() string {
 }
 
 // ClusterNodeAddress represents a cluster node address.
+//
+// Since Redis 7, the address may be followed by the hostname announced by the
+// node, and by auxiliary fields such as `shard-id` or `tls-port`, which are
+// stored in Aux.
 type ClusterNodeAddress struct {
 	IP          net.IP
 	Port        string
 	ClusterPort string
+	Hostname    string
+	Aux         map[string]string
 }
 
-var clusterNodeAddressRegexp = regexp.MustCompile(`^([^:]*):([0-9]*)(@([0-9]*))?$`)
+var clusterNodeAddressRegexp = regexp.MustCompile(`^(.*):([0-9]*)(@([0-9]*))?$`)
 
-// ParseClusterNodeAddress parse a cluster node address.
+// ParseClusterNodeAddress parse a cluster node address, in the
+// `ip:port@cport[,hostname[,key=value...]]` format.
+//
+// IPv6 addresses are supported: the port is always after the last colon.
 func ParseClusterNodeAddress(s string) (result ClusterNodeAddress, err error) {
-	matches := clusterNodeAddressRegexp.FindStringSubmatch(s)
+	parts := strings.Split(s, ",")
+	matches := clusterNodeAddressRegexp.FindStringSubmatch(parts[0])
 
 	if len(matches) != 5 {
 		err = fmt.Errorf("\"%s\" is not a valid cluster node address", s)
 		return
 	}
 
-	result.IP = net.ParseIP(matches[1])
+	result.IP = net.ParseIP(strings.Trim(matches[1], "[]"))
 	result.Port = matches[2]
 	result.ClusterPort = matches[4]
 
+	for i, part := range parts[1:] {
+		index := strings.Index(part, "=")
+
+		if index < 0 {
+			if i > 0 {
+				err = fmt.Errorf("\"%s\" is not a valid cluster node address: invalid auxiliary field \"%s\"", s, part)
+				return
+			}
+
+			result.Hostname = part
+			continue
+		}
+
+		if result.Aux == nil {
+			result.Aux = make(map[string]string)
+		}
+
+		result.Aux[part[:index]] = part[index+1:]
+	}
+
 	return
 }
 
@@ -142,6 +203,22 @@ func (a ClusterNodeAddress) String() string {
 		fmt.Fprintf(buffer, "@%s", a.ClusterPort)
 	}
 
+	if a.Hostname != "" || len(a.Aux) > 0 {
+		fmt.Fprintf(buffer, ",%s", a.Hostname)
+	}
+
+	keys := make([]string, 0, len(a.Aux))
+
+	for key := range a.Aux {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(buffer, ",%s=%s", key, a.Aux[key])
+	}
+
 	return buffer.String()
 }
 
@@ -163,6 +240,9 @@ const (
 	FlagHandshake ClusterNodeFlag = "handshake"
 	// FlagNoAddress indicates the node has no known address.
 	FlagNoAddress ClusterNodeFlag = "noaddr"
+	// FlagNoFailover indicates the node, a slave, won't try to fail over its
+	// master.
+	FlagNoFailover ClusterNodeFlag = "nofailover"
 	// flagNoFlags is used to indicate the absence of flags.
 	flagNoFlags ClusterNodeFlag = "noflags"
 )
@@ -187,6 +267,9 @@ func (f ClusterNodeFlags) String() string {
 }
 
 // ParseClusterNodeFlags parse a list of cluster node flags.
+//
+// Flags unknown to this package, like those introduced by newer Redis
+// versions, are kept as-is.
 func ParseClusterNodeFlags(s string) (result ClusterNodeFlags, err error) {
 	parts := strings.Split(s, ",")
 	result = make(ClusterNodeFlags)
@@ -195,14 +278,14 @@ func ParseClusterNodeFlags(s string) (result ClusterNodeFlags, err error) {
 		flag := ClusterNodeFlag(part)
 
 		switch flag {
-		case FlagMyself, FlagMaster, FlagSlave, FlagProbableFail, FlagFail, FlagHandshake, FlagNoAddress:
-			result[flag] = true
+		case "":
+			err = fmt.Errorf("empty flag in \"%s\"", s)
+			return
 		case flagNoFlags:
 			result = make(ClusterNodeFlags)
 			return
 		default:
-			err = fmt.Errorf("unrecognized flag \"%s\"", part)
-			return
+			result[flag] = true
 		}
 	}
 
@@ -312,17 +395,138 @@ func ParseHashSlots(s string) (slots HashSlots, err error) {
 	}
 }
 
+// ParseHashSlotRanges parses a comma-separated list of hash slots or hash slot
+// ranges, like `0-1000,2000`.
+//
+// The resulting hash slots are sorted, and must be unique and valid.
+func ParseHashSlotRanges(s string) (slots HashSlots, err error) {
+	seen := map[int]bool{}
+
+	for _, part := range strings.Split(s, ",") {
+		var partSlots HashSlots
+
+		if partSlots, err = ParseHashSlots(strings.TrimSpace(part)); err != nil {
+			return nil, err
+		}
+
+		if len(partSlots) == 0 {
+			return nil, fmt.Errorf("parsing \"%s\": empty hash slot range", part)
+		}
+
+		for _, slot := range partSlots {
+			if slot < 0 || slot >= SlotsCount {
+				return nil, fmt.Errorf("parsing \"%s\": hash slot %d is out of range", part, slot)
+			}
+
+			if seen[slot] {
+				return nil, fmt.Errorf("parsing \"%s\": hash slot %d is specified twice", part, slot)
+			}
+
+			seen[slot] = true
+			slots = append(slots, slot)
+		}
+	}
+
+	sort.Ints(slots)
+
+	return
+}
+
+// ParseSlotPin parses slots pinned to a master group, in the
+// `group=instance,...;slots=range,...` form.
+func ParseSlotPin(s string) (masterGroup MasterGroup, slots HashSlots, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("parsing slot pin \"%s\": %s", s, err)
+		}
+	}()
+
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+
+		if len(kv) != 2 {
+			err = fmt.Errorf("expected key=value but got \"%s\"", part)
+			return
+		}
+
+		switch strings.TrimSpace(kv[0]) {
+		case "group":
+			masterGroup, err = ParseMasterGroup(kv[1])
+		case "slots":
+			slots, err = ParseHashSlotRanges(kv[1])
+		default:
+			err = fmt.Errorf("unknown key \"%s\"", kv[0])
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	if len(masterGroup) == 0 {
+		err = errors.New("no master group specified")
+	} else if len(slots) == 0 {
+		err = errors.New("no slots specified")
+	}
+
+	return
+}
+
 // ClusterNode represents a cluster node.
+//
+// Migrating and Importing hold the slots in the middle of a migration, along
+// with the node they are migrated to or imported from. Redis only reports
+// them for the node answering `CLUSTER NODES`, and they are nil if there are
+// none.
+//
+// ReplicationOffset and Health are only reported by `CLUSTER SHARDS`, and
+// are left zero otherwise.
 type ClusterNode struct {
-	ID           ClusterNodeID
-	Address      ClusterNodeAddress
-	Flags        ClusterNodeFlags
-	MasterID     ClusterNodeID
-	PingSent     int
-	PongReceived int
-	Epoch        int
-	LinkState    ClusterNodeLinkState
-	Slots        HashSlots
+	ID                ClusterNodeID
+	Address           ClusterNodeAddress
+	Flags             ClusterNodeFlags
+	MasterID          ClusterNodeID
+	PingSent          int
+	PongReceived      int
+	Epoch             int
+	LinkState         ClusterNodeLinkState
+	Slots             HashSlots
+	Migrating         map[int]ClusterNodeID
+	Importing         map[int]ClusterNodeID
+	ReplicationOffset int64
+	Health            ClusterNodeHealth
+}
+
+// parseSlotMigration parses a slot migration entry, as returned by the
+// `CLUSTER NODES` Redis command: either `[slot->-id]` for a migrating slot
+// or `[slot-<-id]` for an importing one.
+func parseSlotMigration(s string) (slot int, id ClusterNodeID, importing bool, err error) {
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		err = fmt.Errorf("parsing \"%s\": not a slot migration", s)
+		return
+	}
+
+	content := s[1 : len(s)-1]
+	parts := strings.SplitN(content, "->-", 2)
+
+	if len(parts) != 2 {
+		parts = strings.SplitN(content, "-<-", 2)
+		importing = true
+	}
+
+	if len(parts) != 2 || parts[1] == "" {
+		err = fmt.Errorf("parsing \"%s\": unknown slot migration format", s)
+		return
+	}
+
+	if slot, err = strconv.Atoi(parts[0]); err != nil {
+		err = fmt.Errorf("parsing \"%s\": %s", s, err)
+		return
+	}
+
+	id = ClusterNodeID(parts[1])
+
+	return
 }
 
 // ParseClusterNode parse a single cluster node string, as returned by the
@@ -381,6 +585,33 @@ func ParseClusterNode(s string) (result ClusterNode, err error) {
 	var slots HashSlots
 
 	for _, part := range parts[8:] {
+		if strings.HasPrefix(part, "[") {
+			var slot int
+			var id ClusterNodeID
+			var importing bool
+
+			if slot, id, importing, err = parseSlotMigration(part); err != nil {
+				err = fmt.Errorf("parsing \"%s\": %s", s, err)
+				return
+			}
+
+			if importing {
+				if result.Importing == nil {
+					result.Importing = make(map[int]ClusterNodeID)
+				}
+
+				result.Importing[slot] = id
+			} else {
+				if result.Migrating == nil {
+					result.Migrating = make(map[int]ClusterNodeID)
+				}
+
+				result.Migrating[slot] = id
+			}
+
+			continue
+		}
+
 		slots, err = ParseHashSlots(part)
 
 		if err != nil {
@@ -416,9 +647,29 @@ func (n ClusterNode) String() string {
 		fmt.Fprintf(buffer, " %s", n.Slots.String())
 	}
 
+	for _, slot := range sortedSlotKeys(n.Migrating) {
+		fmt.Fprintf(buffer, " [%d->-%s]", slot, n.Migrating[slot])
+	}
+
+	for _, slot := range sortedSlotKeys(n.Importing) {
+		fmt.Fprintf(buffer, " [%d-<-%s]", slot, n.Importing[slot])
+	}
+
 	return buffer.String()
 }
 
+func sortedSlotKeys(m map[int]ClusterNodeID) []int {
+	slots := make([]int, 0, len(m))
+
+	for slot := range m {
+		slots = append(slots, slot)
+	}
+
+	sort.Ints(slots)
+
+	return slots
+}
+
 // ClusterNodes represents a list of cluster nodes.
 type ClusterNodes []ClusterNode
 
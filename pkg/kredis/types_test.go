@@ -1,7 +1,9 @@
 package kredis
 
 import (
+	"io/ioutil"
 	"net"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -136,6 +138,119 @@ func TestParseMasterGroup(t *testing.T) {
 	}
 }
 
+func TestParseWeight(t *testing.T) {
+	redisInstance, weight, err := ParseWeight("redis-0:6380=2.5")
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	expected := RedisInstance{Hostname: "redis-0", Port: "6380"}
+
+	if redisInstance != expected {
+		t.Errorf("expected: %s, got: %s", expected, redisInstance)
+	}
+
+	if weight != 2.5 {
+		t.Errorf("expected: %v, got: %v", 2.5, weight)
+	}
+
+	for _, s := range []string{"redis-0", "=1", "redis-0=heavy", "redis-0=-1", "redis-0=NaN"} {
+		if _, _, err := ParseWeight(s); err == nil {
+			t.Errorf("expected an error for \"%s\"", s)
+		}
+	}
+}
+
+func TestParseClusterNodeAddress(t *testing.T) {
+	testCases := []struct {
+		S              string
+		Expected       *ClusterNodeAddress
+		ExpectedString string
+	}{
+		{
+			"127.0.0.1:30004",
+			&ClusterNodeAddress{IP: net.ParseIP("127.0.0.1"), Port: "30004"},
+			"127.0.0.1:30004",
+		},
+		{
+			"127.0.0.1:30004@31004",
+			&ClusterNodeAddress{IP: net.ParseIP("127.0.0.1"), Port: "30004", ClusterPort: "31004"},
+			"127.0.0.1:30004@31004",
+		},
+		{
+			":0@0",
+			&ClusterNodeAddress{Port: "0", ClusterPort: "0"},
+			":0@0",
+		},
+		{
+			"::1:30004@31004",
+			&ClusterNodeAddress{IP: net.ParseIP("::1"), Port: "30004", ClusterPort: "31004"},
+			"::1:30004@31004",
+		},
+		{
+			"127.0.0.1:30004@31004,redis-0.redis",
+			&ClusterNodeAddress{IP: net.ParseIP("127.0.0.1"), Port: "30004", ClusterPort: "31004", Hostname: "redis-0.redis"},
+			"127.0.0.1:30004@31004,redis-0.redis",
+		},
+		{
+			"127.0.0.1:30004@31004,,tls-port=0,shard-id=69bc",
+			&ClusterNodeAddress{
+				IP:          net.ParseIP("127.0.0.1"),
+				Port:        "30004",
+				ClusterPort: "31004",
+				Aux:         map[string]string{"tls-port": "0", "shard-id": "69bc"},
+			},
+			"127.0.0.1:30004@31004,,shard-id=69bc,tls-port=0",
+		},
+		{
+			"127.0.0.1:30004@31004,redis-0.redis,shard-id=69bc",
+			&ClusterNodeAddress{
+				IP:          net.ParseIP("127.0.0.1"),
+				Port:        "30004",
+				ClusterPort: "31004",
+				Hostname:    "redis-0.redis",
+				Aux:         map[string]string{"shard-id": "69bc"},
+			},
+			"127.0.0.1:30004@31004,redis-0.redis,shard-id=69bc",
+		},
+		{
+			"127.0.0.1:30004@31004,redis-0.redis,invalid",
+			nil,
+			"",
+		},
+		{
+			"127.0.0.1",
+			nil,
+			"",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.S, func(t *testing.T) {
+			value, err := ParseClusterNodeAddress(testCase.S)
+
+			if testCase.Expected == nil {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+			} else {
+				if err != nil {
+					t.Errorf("expected no error but got: %s", err)
+				}
+
+				if !reflect.DeepEqual(*testCase.Expected, value) {
+					t.Errorf("expected:\n%v\ngot:\n%v", testCase.Expected, value)
+				}
+
+				if valueStr := value.String(); valueStr != testCase.ExpectedString {
+					t.Errorf("expected:\n%s\ngot:\n%s", testCase.ExpectedString, valueStr)
+				}
+			}
+		})
+	}
+}
+
 func TestParseClusterNode(t *testing.T) {
 	testCases := []struct {
 		S              string
@@ -215,7 +330,29 @@ func TestParseClusterNode(t *testing.T) {
 			"",
 		},
 		{
-			"b4b2de84dfaecb05ab4d32488ede2517fb95aced 127.0.0.2:6379@16379 invalid abc 2 3 4 disconnected 1 3 5-6 8",
+			"b4b2de84dfaecb05ab4d32488ede2517fb95aced 127.0.0.2:6379@16379 slave,unknown abc 2 3 4 disconnected",
+			&ClusterNode{
+				ID: "b4b2de84dfaecb05ab4d32488ede2517fb95aced",
+				Address: ClusterNodeAddress{
+					IP:          net.ParseIP("127.0.0.2"),
+					Port:        "6379",
+					ClusterPort: "16379",
+				},
+				Flags: ClusterNodeFlags{
+					FlagSlave:                  true,
+					ClusterNodeFlag("unknown"): true,
+				},
+				MasterID:     "abc",
+				PingSent:     2,
+				PongReceived: 3,
+				Epoch:        4,
+				LinkState:    LinkStateDisconnected,
+				Slots:        HashSlots{},
+			},
+			"b4b2de84dfaecb05ab4d32488ede2517fb95aced 127.0.0.2:6379@16379 slave,unknown abc 2 3 4 disconnected",
+		},
+		{
+			"b4b2de84dfaecb05ab4d32488ede2517fb95aced 127.0.0.2:6379@16379 master,,myself abc 2 3 4 disconnected 1 3 5-6 8",
 			nil,
 			"",
 		},
@@ -244,6 +381,37 @@ func TestParseClusterNode(t *testing.T) {
 			nil,
 			"",
 		},
+		{
+			"b4b2de84dfaecb05ab4d32488ede2517fb95aced 127.0.0.2:6379@16379 myself,master - 0 0 4 connected 1-3 [3->-abc] [7-<-def] [2->-abc]",
+			&ClusterNode{
+				ID: "b4b2de84dfaecb05ab4d32488ede2517fb95aced",
+				Address: ClusterNodeAddress{
+					IP:          net.ParseIP("127.0.0.2"),
+					Port:        "6379",
+					ClusterPort: "16379",
+				},
+				Flags: ClusterNodeFlags{
+					FlagMyself: true,
+					FlagMaster: true,
+				},
+				Epoch:     4,
+				LinkState: LinkStateConnected,
+				Slots:     HashSlots{1, 2, 3},
+				Migrating: map[int]ClusterNodeID{2: "abc", 3: "abc"},
+				Importing: map[int]ClusterNodeID{7: "def"},
+			},
+			"b4b2de84dfaecb05ab4d32488ede2517fb95aced 127.0.0.2:6379@16379 master,myself - 0 0 4 connected 1-3 [2->-abc] [3->-abc] [7-<-def]",
+		},
+		{
+			"b4b2de84dfaecb05ab4d32488ede2517fb95aced 127.0.0.2:6379@16379 myself,master - 0 0 4 connected [3-abc]",
+			nil,
+			"",
+		},
+		{
+			"b4b2de84dfaecb05ab4d32488ede2517fb95aced 127.0.0.2:6379@16379 myself,master - 0 0 4 connected [x->-abc]",
+			nil,
+			"",
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -572,3 +740,172 @@ func TestParseHashSlotsErrorInvalidSecondPart(t *testing.T) {
 		t.Error("expected an error")
 	}
 }
+
+func TestParseHashSlotRanges(t *testing.T) {
+	slots, err := ParseHashSlotRanges("5-7, 0")
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	expected := HashSlots{0, 5, 6, 7}
+
+	if !reflect.DeepEqual(slots, expected) {
+		t.Errorf("expected: %v, got: %v", expected, slots)
+	}
+
+	for _, s := range []string{"", "0-1,1", "5-4", "16384", "-1", "a"} {
+		if _, err := ParseHashSlotRanges(s); err == nil {
+			t.Errorf("expected an error for \"%s\"", s)
+		}
+	}
+}
+
+func TestParseSlotPin(t *testing.T) {
+	masterGroup, slots, err := ParseSlotPin("group=redis-0,redis-1;slots=0-2,10")
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	expectedGroup := MasterGroup{{Hostname: "redis-0", Port: "6379"}, {Hostname: "redis-1", Port: "6379"}}
+
+	if !reflect.DeepEqual(masterGroup, expectedGroup) {
+		t.Errorf("expected: %v, got: %v", expectedGroup, masterGroup)
+	}
+
+	expectedSlots := HashSlots{0, 1, 2, 10}
+
+	if !reflect.DeepEqual(slots, expectedSlots) {
+		t.Errorf("expected: %v, got: %v", expectedSlots, slots)
+	}
+
+	for _, s := range []string{"group=redis-0", "slots=0-2", "group=redis-0;slots=0-2;weight=1", "group=redis-0;slots"} {
+		if _, _, err := ParseSlotPin(s); err == nil {
+			t.Errorf("expected an error for \"%s\"", s)
+		}
+	}
+}
+
+// The captured corpus is produced by testdata/capture-cluster-nodes.sh from
+// live clusters, with the Redis version in a header comment.
+func TestParseClusterNodesCapturedCorpus(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "cluster-nodes", "redis-*.txt"))
+
+	if err != nil {
+		t.Fatalf("expected no error but got: %s", err)
+	}
+
+	if len(paths) == 0 {
+		t.Skip("no captured corpus: run testdata/capture-cluster-nodes.sh")
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			data, err := ioutil.ReadFile(path)
+
+			if err != nil {
+				t.Fatalf("expected no error but got: %s", err)
+			}
+
+			lines := strings.SplitN(string(data), "\n", 2)
+
+			if len(lines) != 2 || !strings.HasPrefix(lines[0], "# redis_version:") {
+				t.Fatalf("expected a redis_version header but got: %s", lines[0])
+			}
+
+			nodes, err := ParseClusterNodes(lines[1])
+
+			if err != nil {
+				t.Fatalf("expected no error but got: %s", err)
+			}
+
+			self, err := nodes.Self()
+
+			if err != nil {
+				t.Fatalf("expected no error but got: %s", err)
+			}
+
+			if len(self.Migrating) == 0 || len(self.Importing) == 0 {
+				t.Errorf("expected migrating and importing slots but got: %v", self)
+			}
+
+			if _, err = ParseClusterNodes(nodes.String()); err != nil {
+				t.Errorf("expected no error but got: %s", err)
+			}
+		})
+	}
+}
+
+// The corpus is synthetic: each file is hand-written after the `CLUSTER NODES`
+// format of a Redis version, not captured from a live cluster.
+func TestParseClusterNodesSyntheticCorpus(t *testing.T) {
+	testCases := []struct {
+		Version  string
+		Masters  int
+		Slaves   int
+		SelfID   ClusterNodeID
+		Hostname string
+		ShardID  string
+	}{
+		{Version: "3.2", Masters: 3, Slaves: 3, SelfID: "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca"},
+		{Version: "4.0", Masters: 3, Slaves: 3, SelfID: "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca"},
+		{Version: "5.0", Masters: 3, Slaves: 3, SelfID: "a1f4b9e07c9ba1fc03d8c32e1e1d6b0c4d8f9b21"},
+		{Version: "6.2", Masters: 3, Slaves: 3, SelfID: "5a6b7c8d9e0f1a2b3c4d5e6f708192a3b4c5d6e7"},
+		{Version: "7.0", Masters: 3, Slaves: 3, SelfID: "8f1e2d3c4b5a69788796a5b4c3d2e1f0a9b8c7d6", Hostname: "redis-0.redis.default.svc.cluster.local"},
+		{Version: "7.2", Masters: 3, Slaves: 3, SelfID: "e8f5c0a3b2d1e4f7a6b9c8d7e0f1a2b3c4d5e6f7", ShardID: "69bc080733d1355567173199cff4a6a039a2f024"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Version, func(t *testing.T) {
+			data, err := ioutil.ReadFile(filepath.Join("testdata", "synthetic-cluster-nodes", "redis-"+testCase.Version+".txt"))
+
+			if err != nil {
+				t.Fatalf("expected no error but got: %s", err)
+			}
+
+			nodes, err := ParseClusterNodes(string(data))
+
+			if err != nil {
+				t.Fatalf("expected no error but got: %s", err)
+			}
+
+			masters, slaves := 0, 0
+
+			for _, node := range nodes {
+				if node.Flags[FlagMaster] {
+					masters++
+				} else if node.Flags[FlagSlave] {
+					slaves++
+				}
+			}
+
+			if masters != testCase.Masters || slaves != testCase.Slaves {
+				t.Errorf("expected %d masters and %d slaves but got %d and %d", testCase.Masters, testCase.Slaves, masters, slaves)
+			}
+
+			self, err := nodes.Self()
+
+			if err != nil {
+				t.Fatalf("expected no error but got: %s", err)
+			}
+
+			if self.ID != testCase.SelfID {
+				t.Errorf("expected: %s, got: %s", testCase.SelfID, self.ID)
+			}
+
+			if self.Address.Hostname != testCase.Hostname {
+				t.Errorf("expected: `%s`, got: `%s`", testCase.Hostname, self.Address.Hostname)
+			}
+
+			if shardID := self.Address.Aux["shard-id"]; shardID != testCase.ShardID {
+				t.Errorf("expected: `%s`, got: `%s`", testCase.ShardID, shardID)
+			}
+
+			// Rendering the nodes must give back parseable nodes.
+			if _, err = ParseClusterNodes(nodes.String()); err != nil {
+				t.Errorf("expected no error but got: %s", err)
+			}
+		})
+	}
+}